@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type adminCommand struct {
+	usage string
+	run   func(args []string) (string, error)
+}
+
+var adminCommands map[string]adminCommand
+
+func init() {
+	// Assigned in init, because help refers to the map itself
+	adminCommands = map[string]adminCommand{
+		"help":      {"help - show this help", adminHelp},
+		"rounds":    {"rounds - list rounds", adminRounds},
+		"players":   {"players [round] - list players of all rounds or of one round", adminPlayers},
+		"kick":      {"kick <round> <name> - disconnect the player", adminKick},
+		"mute":      {"mute <round> <name> - hide the player's name from others", adminMute(true)},
+		"unmute":    {"unmute <round> <name> - show the player's name again", adminMute(false)},
+		"finish":    {"finish <round> - finish the running round", adminFinish},
+		"broadcast": {"broadcast <message> - show the message to all players", adminBroadcast},
+		"bots":      {"bots [amount] - show or change the maximum amount of bots per round", adminBots},
+		"botlevel":  {"botlevel [round] [level] - show or change the level of bots in new rounds or in the round", adminBotLevel},
+		"bans":      {"bans - list banned IPs and names", adminBans},
+		"ban":       {"ban <ip|cidr> - ban new connections from the address, kick to remove current ones", adminBan((*banList).banIP)},
+		"unban":     {"unban <ip|cidr> - remove the address from bans", adminBan((*banList).unbanIP)},
+		"banname":   {"banname <name> - forbid the name", adminBanName((*banList).banName)},
+		"unbanname": {"unbanname <name> - allow the name again", adminBanName((*banList).unbanName)},
+		"blocklist": {"blocklist - reload the name blocklist file", adminBlocklist},
+		"scripts":   {"scripts - reload bot scripts from the artifacts", adminScripts},
+	}
+}
+
+/*
+Without the token the console has no authentication, so only loopback
+addresses and sockets of the owner of the process may be listened
+*/
+func restrictAdmin(l net.Listener, token string) error {
+	if !isLocal(l) && token == "" {
+		return errors.New("Admin console on a network address needs -admin-token")
+	}
+	return nil
+}
+
+func serveAdmin(l net.Listener, token string) {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			conf.Log.Error("Failed to accept admin connection", "err", err)
+			continue
+		}
+		go handleAdmin(conn, token)
+	}
+}
+
+func handleAdmin(conn net.Conn, token string) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	if token != "" {
+		fmt.Fprint(conn, "Token: ")
+		if !scanner.Scan() || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(scanner.Text())), []byte(token)) != 1 {
+			conf.Log.Warn("Admin failed to authenticate", "addr", addrOf(conn))
+			fmt.Fprint(conn, "Wrong token\n")
+			return
+		}
+	}
+	conf.Log.Info("Admin connected", "addr", addrOf(conn))
+
+	fmt.Fprint(conn, "crashci admin console, type help for the list of commands\n> ")
+	for scanner.Scan() {
+		output, err := runAdminCommand(scanner.Text())
+		if err != nil {
+			output = "Error: " + err.Error() + "\n"
+		}
+		if _, err := fmt.Fprint(conn, output+"> "); err != nil {
+			return
+		}
+	}
+}
+
+func runAdminCommand(line string) (string, error) {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return "", nil
+	}
+
+	command, ok := adminCommands[args[0]]
+	if !ok {
+		return "", fmt.Errorf("Unknown command %q", args[0])
+	}
+	conf.Log.Info("Admin command", "command", line)
+	return command.run(args[1:])
+}
+
+func adminHelp(args []string) (string, error) {
+	var usages []string
+	for _, command := range adminCommands {
+		usages = append(usages, command.usage)
+	}
+	sort.Strings(usages)
+	return strings.Join(usages, "\n") + "\n", nil
+}
+
+func adminRounds(args []string) (string, error) {
+	var b strings.Builder
+	for _, round := range registry.list() {
+		fmt.Fprintf(&b, "%d\t%s\t%s\tplayers: %d\thumans: %d\tbots: %s\tsince: %s\n",
+			round.Id, stateNames[round.State], round.ModeName, len(round.Players), round.humans(), round.BotLevel,
+			time.Since(round.LastStateChange).Truncate(time.Second))
+	}
+	return b.String(), nil
+}
+
+func adminPlayers(args []string) (string, error) {
+	rounds := registry.list()
+	if len(args) > 0 {
+		round, err := parseRound(args[0])
+		if err != nil {
+			return "", err
+		}
+		rounds = []*Round{round}
+	}
+
+	var b strings.Builder
+	for _, round := range rounds {
+		for _, p := range round.Players {
+			addr := "bot"
+			if !p.Bot {
+				addr = addrOf(p.Conn)
+			}
+			fmt.Fprintf(&b, "%d\t%s\t%s\thealth: %d\tbombs: %d\tmuted: %t\n",
+				round.Id, p.Name, addr, p.Health, p.Bombs, p.Muted)
+		}
+	}
+	return b.String(), nil
+}
+
+func adminKick(args []string) (string, error) {
+	round, player, err := parsePlayer(args)
+	if err != nil {
+		return "", err
+	}
+	kickPlayer(round, player)
+	return fmt.Sprintf("%s was kicked\n", player.Name), nil
+}
+
+func adminMute(muted bool) func(args []string) (string, error) {
+	return func(args []string) (string, error) {
+		_, player, err := parsePlayer(args)
+		if err != nil {
+			return "", err
+		}
+		player.Muted = muted
+		return fmt.Sprintf("%s muted: %t\n", player.Name, muted), nil
+	}
+}
+
+func adminFinish(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("Round is required")
+	}
+	round, err := parseRound(args[0])
+	if err != nil {
+		return "", err
+	}
+	if err := round.finish(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Round %d is finished\n", round.Id), nil
+}
+
+func adminBroadcast(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("Message is required")
+	}
+	rounds := broadcastMessage(strings.Join(args, " "))
+	return fmt.Sprintf("Message was sent to %d rounds\n", rounds), nil
+}
+
+func adminBots(args []string) (string, error) {
+	if len(args) > 0 {
+		bots, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || bots < 0 || bots >= maxPlayersPerRound {
+			return "", fmt.Errorf("Amount of bots must be between 0 and %d", maxPlayersPerRound-1)
+		}
+		atomic.StoreInt64(&botsPerRound, bots)
+	}
+	return fmt.Sprintf("Bots per round: %d\n", atomic.LoadInt64(&botsPerRound)), nil
+}
+
+func adminBotLevel(args []string) (string, error) {
+	switch len(args) {
+	case 0:
+		return fmt.Sprintf("Bot level of new rounds: %s\nKnown levels: %s\n", defaultRoundBotLevel.Load(), strings.Join(botLevelNames(), ", ")), nil
+	case 1:
+		if _, err := newBotBrain(args[0]); err != nil {
+			return "", err
+		}
+		defaultRoundBotLevel.Store(args[0])
+		return fmt.Sprintf("Bot level of new rounds: %s\n", args[0]), nil
+	}
+
+	round, err := parseRound(args[0])
+	if err != nil {
+		return "", err
+	}
+	if _, err := newBotBrain(args[1]); err != nil {
+		return "", err
+	}
+	round.BotLevel = args[1]
+	return fmt.Sprintf("Bot level of the round %d: %s\n", round.Id, args[1]), nil
+}
+
+func adminBans(args []string) (string, error) {
+	return conf.Bans.String(), nil
+}
+
+func adminBan(action func(*banList, string) error) func(args []string) (string, error) {
+	return func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", errors.New("IP or network is required")
+		}
+		if err := action(conf.Bans, args[0]); err != nil {
+			return "", err
+		}
+		return conf.Bans.String(), nil
+	}
+}
+
+func adminBanName(action func(*banList, string) error) func(args []string) (string, error) {
+	return func(args []string) (string, error) {
+		if len(args) == 0 {
+			return "", errors.New("Name is required")
+		}
+		if err := action(conf.Bans, strings.Join(args, " ")); err != nil {
+			return "", err
+		}
+		return conf.Bans.String(), nil
+	}
+}
+
+// Running cars keep the old version of the script till the end of the round
+func adminScripts(args []string) (string, error) {
+	scripts, err := loadBotScripts(conf.AcidPath)
+	botScripts.Store(scripts)
+	if err != nil {
+		return "", fmt.Errorf("%d scripts loaded, others failed: %w", len(scripts), err)
+	}
+	return fmt.Sprintf("Bot scripts: %s\n", strings.Join(scriptNames(), ", ")), nil
+}
+
+func adminBlocklist(args []string) (string, error) {
+	if err := conf.Blocklist.reload(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Name blocklist has %d words\n", conf.Blocklist.size()), nil
+}
+
+func parseRound(id string) (*Round, error) {
+	roundId, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("Bad round %q", id)
+	}
+	round := registry.get(roundId)
+	if round == nil {
+		return nil, fmt.Errorf("Round %d does not exist", roundId)
+	}
+	return round, nil
+}
+
+// Names may contain spaces, so everything after the round is the name
+func parsePlayer(args []string) (*Round, *Player, error) {
+	if len(args) < 2 {
+		return nil, nil, errors.New("Round and name are required")
+	}
+	round, err := parseRound(args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	player := round.findPlayer(strings.Join(args[1:], " "))
+	if player == nil {
+		return nil, nil, fmt.Errorf("Player %q is not in the round %d", strings.Join(args[1:], " "), round.Id)
+	}
+	return round, player, nil
+}
+
+func kickPlayer(round *Round, player *Player) {
+	player.logger(round).Info("Player was kicked")
+	player.Health = 0
+	if !player.Bot {
+		disconnect(player.Conn, reasonKicked)
+	}
+}
+
+func broadcastMessage(message string) int {
+	rounds := registry.list()
+	for _, round := range rounds {
+		round.broadcast(message)
+	}
+	return len(rounds)
+}
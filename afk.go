@@ -0,0 +1,106 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Time between the AFK warning and the action
+const afkWarningSec = 10
+
+// What to do with AFK players
+const (
+	AFK_BOT  = "bot"
+	AFK_KICK = "kick"
+)
+
+func (player *Player) touch() {
+	atomic.StoreInt64(&player.LastInput, time.Now().Unix())
+	if player.Afk {
+		player.Afk = false
+		player.AutoPilot = false
+	}
+}
+
+/*
+Watches the human player while the round is running. After AfkTimeout without
+input the player gets a warning in the sidebar and afkWarningSec later the car
+is given to the bot or the player is kicked. Any key gives the control back.
+Players who lost the connection are handled by waitReconnect instead
+*/
+func (player *Player) checkIdle(round *Round) {
+	if conf.AfkTimeout <= 0 {
+		return
+	}
+
+	owner := atomic.LoadInt64(&player.Owner)
+	for {
+		if player.Health <= 0 || round.State == FINISHED || player.Bot || !player.ownedBy(owner) {
+			return
+		}
+		if player.waitingReconnect() {
+			time.Sleep(time.Second)
+			continue
+		}
+		if round.State != RUNNING {
+			// Time before the start doesn't count
+			player.touch()
+			time.Sleep(time.Second)
+			continue
+		}
+
+		idle := time.Now().Unix() - atomic.LoadInt64(&player.LastInput)
+		timeout := int64(conf.AfkTimeout.Seconds())
+		if idle >= timeout && !player.Afk {
+			player.logger(round).Info("Player is AFK")
+			player.Afk = true
+		} else if idle >= timeout+afkWarningSec && !player.AutoPilot {
+			if !player.afkAction(round, owner) {
+				return
+			}
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// Kicks the player or gives the car to the bot, false if the player is gone
+func (player *Player) afkAction(round *Round, owner int64) bool {
+	// The car may be taken over by the newcomer meanwhile
+	round.Lock()
+	defer round.Unlock()
+	if !player.ownedBy(owner) {
+		return false
+	}
+
+	if conf.AfkAction == AFK_KICK {
+		player.logger(round).Info("AFK player was kicked")
+		player.Health = 0
+		disconnect(player.Conn, reasonAfk)
+		return false
+	}
+	player.logger(round).Info("AFK player is replaced by the bot")
+	player.AutoPilot = true
+	go player.moveBot(round)
+	return true
+}
+
+/*
+A human on autopilot keeps the round running for another AfkTimeout,
+so a player alone with bots has the time to come back
+*/
+func (player *Player) afkGraceOver() bool {
+	idle := time.Now().Unix() - atomic.LoadInt64(&player.LastInput)
+	return idle >= 2*int64(conf.AfkTimeout.Seconds())+afkWarningSec
+}
+
+// Seconds left before the AFK action or -1 if the player is not warned
+func (player *Player) afkSecondsLeft() int64 {
+	if !player.Afk || player.AutoPilot {
+		return -1
+	}
+	left := atomic.LoadInt64(&player.LastInput) + int64(conf.AfkTimeout.Seconds()) + afkWarningSec - time.Now().Unix()
+	if left < 0 {
+		return 0
+	}
+	return left
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	serverStarted    = time.Now()
+	totalConnections int64
+	totalRounds      int64
+	activeClients    int64
+)
+
+type playerStatus struct {
+	Name   string `json:"name"`
+	Bot    bool   `json:"bot"`
+	Team   int    `json:"team"`
+	Health int64  `json:"health"`
+	Bombs  int    `json:"bombs"`
+	Muted  bool   `json:"muted"`
+}
+
+// IDs are strings, because JavaScript can't handle 64 bit integers
+type roundStatus struct {
+	Id         int            `json:"id,string"`
+	State      string         `json:"state"`
+	BotLevel   string         `json:"bot_level"`
+	Mode       string         `json:"mode"`
+	Teams      int            `json:"teams"`
+	Players    []playerStatus `json:"players"`
+	ElapsedSec int64          `json:"elapsed_sec"`
+}
+
+type serverStatus struct {
+	UptimeSec        int64 `json:"uptime_sec"`
+	ConnectedClients int64 `json:"connected_clients"`
+	Rounds           int   `json:"rounds"`
+	TotalConnections int64 `json:"total_connections"`
+	TotalRounds      int64 `json:"total_rounds"`
+}
+
+type kickRequest struct {
+	Round int    `json:"round,string"`
+	Name  string `json:"name"`
+}
+
+type broadcastRequest struct {
+	Message string `json:"message"`
+}
+
+func registerAPI(mux *http.ServeMux, token string) {
+	mux.HandleFunc("/api/status", apiStatus)
+	mux.HandleFunc("/api/rounds", apiRounds)
+	mux.HandleFunc("/api/kick", apiAuth(token, apiKick))
+	mux.HandleFunc("/api/broadcast", apiAuth(token, apiBroadcast))
+}
+
+/*
+Actions are allowed only with POST and the token from -api-token.
+Without a token they are disabled completely
+*/
+func apiAuth(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			apiError(w, http.StatusMethodNotAllowed, "Only POST is allowed")
+			return
+		}
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			apiError(w, http.StatusUnauthorized, "Bad token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func apiStatus(w http.ResponseWriter, r *http.Request) {
+	apiReply(w, serverStatus{
+		UptimeSec:        int64(time.Since(serverStarted).Seconds()),
+		ConnectedClients: atomic.LoadInt64(&activeClients),
+		Rounds:           len(registry.list()),
+		TotalConnections: atomic.LoadInt64(&totalConnections),
+		TotalRounds:      atomic.LoadInt64(&totalRounds),
+	})
+}
+
+func apiRounds(w http.ResponseWriter, r *http.Request) {
+	statuses := []roundStatus{}
+	for _, round := range registry.list() {
+		status := roundStatus{
+			Id:         round.Id,
+			State:      stateNames[round.State],
+			BotLevel:   round.BotLevel,
+			Mode:       round.ModeName,
+			Teams:      round.Teams,
+			Players:    []playerStatus{},
+			ElapsedSec: int64(time.Since(round.LastStateChange).Seconds()),
+		}
+		for _, p := range round.Players {
+			status.Players = append(status.Players, playerStatus{p.Name, p.Bot, p.Team, p.Health, p.Bombs, p.Muted})
+		}
+		statuses = append(statuses, status)
+	}
+	apiReply(w, statuses)
+}
+
+func apiKick(w http.ResponseWriter, r *http.Request) {
+	var req kickRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apiError(w, http.StatusBadRequest, "Bad request: "+err.Error())
+		return
+	}
+
+	round := registry.get(req.Round)
+	if round == nil {
+		apiError(w, http.StatusNotFound, "Round does not exist")
+		return
+	}
+	player := round.findPlayer(req.Name)
+	if player == nil {
+		apiError(w, http.StatusNotFound, "Player is not in the round")
+		return
+	}
+	conf.Log.Info("API kick", "round", req.Round, "player", req.Name, "remote", r.RemoteAddr)
+	kickPlayer(round, player)
+	apiReply(w, map[string]string{"result": "kicked"})
+}
+
+func apiBroadcast(w http.ResponseWriter, r *http.Request) {
+	var req broadcastRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Message == "" {
+		apiError(w, http.StatusBadRequest, "Message is required")
+		return
+	}
+	conf.Log.Info("API broadcast", "message", req.Message, "remote", r.RemoteAddr)
+	apiReply(w, map[string]int{"rounds": broadcastMessage(req.Message)})
+}
+
+func apiReply(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		conf.Log.Error("Failed to write API reply", "err", err)
+	}
+}
+
+func apiError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+/*
+banList is stored as JSON, so operators may edit it by hand as well.
+IPs may be single addresses or CIDR networks, names are case insensitive
+*/
+type banList struct {
+	sync.Mutex `json:"-"`
+	path       string
+	IPs        []string `json:"ips"`
+	Names      []string `json:"names"`
+	networks   []*net.IPNet
+}
+
+func loadBans(path string) (*banList, error) {
+	bans := &banList{path: path}
+	if path == "" {
+		return bans, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return bans, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, bans); err != nil {
+		return nil, fmt.Errorf("Bad ban list %s: %w", path, err)
+	}
+	// Hand edited entries are kept like the console stores them, so they can be unbanned
+	for i, ip := range bans.IPs {
+		network, err := parseNetwork(ip)
+		if err != nil {
+			return nil, err
+		}
+		bans.IPs[i] = network.String()
+		bans.networks = append(bans.networks, network)
+	}
+	return bans, nil
+}
+
+// Single addresses are networks with the full mask
+func parseNetwork(ip string) (*net.IPNet, error) {
+	if !strings.Contains(ip, "/") {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return nil, fmt.Errorf("Bad IP %q", ip)
+		}
+		bits := 8 * net.IPv6len
+		if parsed.To4() != nil {
+			parsed, bits = parsed.To4(), 8*net.IPv4len
+		}
+		return &net.IPNet{IP: parsed, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(ip)
+	if err != nil {
+		return nil, fmt.Errorf("Bad network %q", ip)
+	}
+	return network, nil
+}
+
+func (b *banList) save() error {
+	if b.path == "" {
+		return nil
+	}
+	return writeFileAtomic(b.path, b)
+}
+
+// Writes v as JSON to a temporary file and renames it, so a crash never leaves half of the file
+func writeFileAtomic(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Replaces the lists, the old ones stay if the new ones can't be saved
+func (b *banList) update(ips []string, networks []*net.IPNet, names []string) error {
+	oldIPs, oldNetworks, oldNames := b.IPs, b.networks, b.Names
+	b.IPs, b.networks, b.Names = ips, networks, names
+	if err := b.save(); err != nil {
+		b.IPs, b.networks, b.Names = oldIPs, oldNetworks, oldNames
+		return err
+	}
+	return nil
+}
+
+func (b *banList) bannedIP(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		// Clients of Unix sockets don't have IPs
+		return false
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	for _, network := range b.networks {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *banList) bannedName(name string) bool {
+	b.Lock()
+	defer b.Unlock()
+	for _, banned := range b.Names {
+		if strings.EqualFold(banned, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *banList) banIP(ip string) error {
+	network, err := parseNetwork(ip)
+	if err != nil {
+		return err
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	if slices.Contains(b.IPs, network.String()) {
+		return fmt.Errorf("%s is already banned", network)
+	}
+	return b.update(append(slices.Clip(b.IPs), network.String()), append(slices.Clip(b.networks), network), b.Names)
+}
+
+func (b *banList) unbanIP(ip string) error {
+	network, err := parseNetwork(ip)
+	if err != nil {
+		return err
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	i := slices.Index(b.IPs, network.String())
+	if i == -1 {
+		return fmt.Errorf("%s is not banned", network)
+	}
+	return b.update(slices.Delete(slices.Clone(b.IPs), i, i+1), slices.Delete(slices.Clone(b.networks), i, i+1), b.Names)
+}
+
+func (b *banList) banName(name string) error {
+	b.Lock()
+	defer b.Unlock()
+	if slices.ContainsFunc(b.Names, func(banned string) bool { return strings.EqualFold(banned, name) }) {
+		return fmt.Errorf("%q is already banned", name)
+	}
+	return b.update(b.IPs, b.networks, append(slices.Clip(b.Names), name))
+}
+
+func (b *banList) unbanName(name string) error {
+	b.Lock()
+	defer b.Unlock()
+	i := slices.IndexFunc(b.Names, func(banned string) bool { return strings.EqualFold(banned, name) })
+	if i == -1 {
+		return fmt.Errorf("%q is not banned", name)
+	}
+	return b.update(b.IPs, b.networks, slices.Delete(slices.Clone(b.Names), i, i+1))
+}
+
+func (b *banList) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return fmt.Sprintf("IPs: %s\nNames: %s\n", strings.Join(b.IPs, ", "), strings.Join(b.Names, ", "))
+}
+
+/*
+nameBlocklist is a file with forbidden words, one per line. Names containing
+any of them are not allowed regardless of the case
+*/
+type nameBlocklist struct {
+	sync.Mutex
+	path  string
+	words []string
+}
+
+func newNameBlocklist(path string) (*nameBlocklist, error) {
+	blocklist := &nameBlocklist{path: path}
+	return blocklist, blocklist.reload()
+}
+
+func (n *nameBlocklist) reload() error {
+	if n.path == "" {
+		return nil
+	}
+
+	f, err := os.Open(n.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if word != "" && !strings.HasPrefix(word, "#") {
+			words = append(words, word)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	n.Lock()
+	n.words = words
+	n.Unlock()
+	return nil
+}
+
+func (n *nameBlocklist) blocked(name string) bool {
+	name = strings.ToLower(name)
+
+	n.Lock()
+	defer n.Unlock()
+	for _, word := range n.words {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *nameBlocklist) size() int {
+	n.Lock()
+	defer n.Unlock()
+	return len(n.words)
+}
+
+var errNameNotAllowed = errors.New("Name is not allowed")
+
+func checkNameAllowed(name string) error {
+	if conf.Bans.bannedName(name) || conf.Blocklist.blocked(name) {
+		return errNameNotAllowed
+	}
+	return nil
+}
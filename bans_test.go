@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseNetwork(t *testing.T) {
+	tests := []struct {
+		ip      string
+		network string
+		err     bool
+	}{
+		{"10.0.0.1", "10.0.0.1/32", false},
+		{"10.0.0.5/8", "10.0.0.0/8", false},
+		{"::ffff:10.0.0.1", "10.0.0.1/32", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"2001:db8::1/32", "2001:db8::/32", false},
+		{"10.0.0.256", "", true},
+		{"10.0.0.0/33", "", true},
+		{"", "", true},
+	}
+	for _, test := range tests {
+		network, err := parseNetwork(test.ip)
+		if test.err {
+			if err == nil {
+				t.Errorf("parseNetwork(%q) = %s, want an error", test.ip, network)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseNetwork(%q) failed: %v", test.ip, err)
+		} else if network.String() != test.network {
+			t.Errorf("parseNetwork(%q) = %s, want %s", test.ip, network, test.network)
+		}
+	}
+}
+
+func TestLoadBansNormalizes(t *testing.T) {
+	tests := []struct {
+		file string
+		ips  []string
+		err  bool
+	}{
+		{`{"ips": ["10.0.0.1", "10.0.0.5/8"]}`, []string{"10.0.0.1/32", "10.0.0.0/8"}, false},
+		{`{"ips": ["2001:db8::1"]}`, []string{"2001:db8::1/128"}, false},
+		{`{"names": ["troll"]}`, nil, false},
+		{`{"ips": ["localhost"]}`, nil, true},
+		{`{"ips": `, nil, true},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "bans.json")
+		if err := os.WriteFile(path, []byte(test.file), 0600); err != nil {
+			t.Fatal(err)
+		}
+		bans, err := loadBans(path)
+		if test.err {
+			if err == nil {
+				t.Errorf("loadBans(%s) succeeded, want an error", test.file)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("loadBans(%s) failed: %v", test.file, err)
+		} else if !slices.Equal(bans.IPs, test.ips) {
+			t.Errorf("loadBans(%s) IPs = %v, want %v", test.file, bans.IPs, test.ips)
+		}
+	}
+}
+
+// Hand edited entries can be unbanned the way they were written
+func TestUnbanLoaded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	if err := os.WriteFile(path, []byte(`{"ips": ["10.0.0.1", "192.168.1.7/24"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	bans, err := loadBans(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ip := range []string{"10.0.0.1", "192.168.1.0/24"} {
+		if err := bans.unbanIP(ip); err != nil {
+			t.Errorf("unbanIP(%q) failed: %v", ip, err)
+		}
+	}
+	if bans.bannedIP("10.0.0.1") || bans.bannedIP("192.168.1.20") {
+		t.Errorf("IPs are still banned: %v", bans.IPs)
+	}
+}
+
+// The ban list in memory stays like the file when the file can't be written
+func TestBansRollBack(t *testing.T) {
+	bans, err := loadBans(filepath.Join(t.TempDir(), "missing", "bans.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bans.banIP("10.0.0.1"); err == nil {
+		t.Error("banIP succeeded without the directory of the list")
+	}
+	if err := bans.banName("troll"); err == nil {
+		t.Error("banName succeeded without the directory of the list")
+	}
+	if bans.bannedIP("10.0.0.1") || bans.bannedName("troll") || len(bans.IPs) > 0 {
+		t.Errorf("Failed bans are kept: %s", bans)
+	}
+}
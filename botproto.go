@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+/*
+External bots are programs playing over a separate listener. The protocol is
+newline delimited JSON. The bot sends {"name": "..."} first and joins a round
+like a human. Then it gets a "state" event every frame and sends actions
+{"direction": "left|right|up|down", "bomb": true} at any time
+*/
+
+var directionNames = [...]string{LEFT: "left", RIGHT: "right", UP: "up", DOWN: "down"}
+
+type botHello struct {
+	Name string `json:"name"`
+}
+
+type botCommand struct {
+	Direction string `json:"direction,omitempty"`
+	Bomb      bool   `json:"bomb,omitempty"`
+}
+
+type botPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// Type is the name of the power-up like in -powerups
+type botPowerUp struct {
+	botPoint
+	Type string `json:"type"`
+}
+
+// Position of the left upper corner and the size
+type botRect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type botCar struct {
+	botRect
+	Name      string `json:"name"`
+	Bot       bool   `json:"bot"`
+	Team      int    `json:"team"`
+	Health    int64  `json:"health"`
+	Bombs     int    `json:"bombs"`
+	Direction string `json:"direction"`
+	Speed     int64  `json:"speed"`
+}
+
+// Events of types welcome, message, error and over. Over has the winner, empty for a draw
+type botEvent struct {
+	Type   string `json:"type"`
+	Text   string `json:"text,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Winner string `json:"winner,omitempty"`
+}
+
+/*
+Sent every frame. Me is the index of the bot's car in Cars, Bonus is the closest heart or null without hearts.
+Walls and Goal are sent only in modes which have them
+*/
+type botState struct {
+	Type     string       `json:"type"`
+	Round    int          `json:"round,string"`
+	State    string       `json:"state"`
+	Me       int          `json:"me"`
+	Cars     []botCar     `json:"cars"`
+	Bombs    []botPoint   `json:"bombs"`
+	Bonus    *botPoint    `json:"bonus"`
+	PowerUps []botPowerUp `json:"powerups"`
+	Arena    botRect      `json:"arena"`
+	Walls    []botPoint   `json:"walls,omitempty"`
+	Goal     *botPoint    `json:"goal,omitempty"`
+}
+
+func parseDirection(name string) (int, error) {
+	for direction, n := range directionNames {
+		if n == name {
+			return direction, nil
+		}
+	}
+	return -1, fmt.Errorf("Unknown direction %q", name)
+}
+
+func toBotRect(r Rectangle) botRect {
+	return botRect{
+		X:      r.Points[LEFTUP].X,
+		Y:      r.Points[LEFTUP].Y,
+		Width:  r.Points[RIGHTDOWN].X - r.Points[LEFTUP].X + 1,
+		Height: r.Points[RIGHTDOWN].Y - r.Points[LEFTUP].Y + 1,
+	}
+}
+
+func (round *Round) botState(me *Player) botState {
+	state := newBotState(round.snapshot(me))
+	state.Round = round.Id
+	return state
+}
+
+func newBotState(s *RoundSnapshot) botState {
+	event := botState{Type: "state", State: stateNames[s.State], Me: s.Me, Bombs: []botPoint{}, PowerUps: []botPowerUp{}, Arena: toBotRect(s.Arena)}
+	for _, c := range s.Cars {
+		event.Cars = append(event.Cars, botCar{toBotRect(c.Borders), c.Name, c.Bot, c.Team, c.Health, c.Bombs, directionNames[c.Direction], c.Speed})
+	}
+	for _, b := range s.Bombs {
+		event.Bombs = append(event.Bombs, botPoint{b.X, b.Y})
+	}
+	for _, p := range s.PowerUps {
+		event.PowerUps = append(event.PowerUps, botPowerUp{botPoint{p.Position.X, p.Position.Y}, powerUps[p.Kind].Name})
+	}
+	if s.hasBonus() {
+		event.Bonus = &botPoint{s.Bonus.X, s.Bonus.Y}
+	}
+	for _, w := range s.Walls {
+		event.Walls = append(event.Walls, botPoint{w.X, w.Y})
+	}
+	if s.hasGoal() {
+		event.Goal = &botPoint{s.Goal.X, s.Goal.Y}
+	}
+	return event
+}
+
+func sendEvent(conn net.Conn, event any) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	// One write per event, so concurrent events don't mix
+	_, err = conn.Write(append(data, '\n'))
+	return err
+}
+
+func (player *Player) sendEvent(round *Round, event any) {
+	if sendEvent(player.Conn, event) != nil {
+		player.lost(round, reasonWriteError)
+	}
+}
+
+func getBotData(conn net.Conn) (Player, error) {
+	if conf.NameTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(conf.NameTimeout))
+		defer conn.SetReadDeadline(time.Time{})
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return Player{}, fmt.Errorf("%w: %w", errCommunication, err)
+	}
+
+	var hello botHello
+	if err := json.Unmarshal(line, &hello); err != nil {
+		return Player{}, fmt.Errorf("Bad hello: %w", err)
+	}
+	name := strings.TrimSpace(hello.Name)
+	if err := validateName(name); err != nil {
+		return Player{}, err
+	}
+	if err := checkNameAllowed(name); err != nil {
+		return Player{}, err
+	}
+
+	return Player{Conn: conn, Name: name, Health: 100, External: true, Car: Car{Speed: 1}}, nil
+}
+
+/*
+Reads actions of the external bot. Turning around stops the car,
+the same way it does for humans
+*/
+func (player *Player) readActions(round *Round) {
+	scanner := bufio.NewScanner(player.Conn)
+	for scanner.Scan() {
+		if player.Health <= 0 || round.State == FINISHED {
+			return
+		}
+
+		var command botCommand
+		if err := json.Unmarshal(scanner.Bytes(), &command); err != nil {
+			go player.sendEvent(round, botEvent{Type: "error", Error: "Bad action: " + err.Error()})
+			continue
+		}
+		if command.Direction != "" {
+			direction, err := parseDirection(command.Direction)
+			if err != nil {
+				go player.sendEvent(round, botEvent{Type: "error", Error: err.Error()})
+				continue
+			}
+			player.turn(direction)
+		}
+		if command.Bomb && player.Bombs > 0 {
+			player.DropBomb = true
+		}
+	}
+	if player.Health > 0 && round.State != FINISHED {
+		player.lost(round, reasonReadError)
+	}
+}
+
+func serveBots(l net.Listener, compileRoundChannel chan *Round) {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			conf.Log.Error("Failed to accept bot", "err", err)
+			continue
+		}
+
+		go handleBot(conn, compileRoundChannel)
+	}
+}
+
+func handleBot(conn net.Conn, compileRoundChannel chan *Round) {
+	limited, reason := conf.Limiter.limit(conn)
+	if reason != "" {
+		conf.Log.Info("Bot rejected", "addr", addrOf(conn), "reason", reason)
+		rejects.WithLabelValues(reason).Inc()
+		sendEvent(conn, botEvent{Type: "error", Error: rejectMessages[reason]})
+		conn.Close()
+		return
+	}
+	conn = newMeteredConn(limited)
+
+	users := atomic.AddInt64(&totalConnections, 1)
+	conf.Log.Info("Bot connected", "event", eventConnect, "addr", addrOf(conn), "total", users)
+
+	if conf.Bans.bannedIP(ipOf(conn)) {
+		conf.Log.Info("Banned address rejected", "addr", addrOf(conn))
+		sendEvent(conn, botEvent{Type: "error", Error: "Address is banned"})
+		disconnect(conn, reasonBanned)
+		return
+	}
+
+	p, err := getBotData(conn)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		sendEvent(conn, botEvent{Type: "error", Error: "Time to send the name is over"})
+		disconnect(conn, reasonTimeout)
+		return
+	} else if err != nil {
+		conf.Log.Info("Name rejected", "event", eventNameRejected, "addr", addrOf(conn), "err", err)
+		if !errors.Is(err, errCommunication) {
+			sendEvent(conn, botEvent{Type: "error", Error: err.Error()})
+		}
+		disconnect(conn, reasonBadName)
+		return
+	}
+	conf.Log.Info("Name accepted", "event", eventNameAccepted, "addr", addrOf(conn), "player", p.Name, "external", true)
+	annotateConn(conn, "player", p.Name)
+
+	if sendEvent(conn, botEvent{Type: "welcome", Text: "Waiting for a round"}) != nil {
+		disconnect(conn, reasonWriteError)
+		return
+	}
+	p.checkBestRoundForPlayer(compileRoundChannel)
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"go.starlark.net/starlark"
+)
+
+/*
+CarState is a copy of a car taken for the bot, so the bot can't change
+anything in the round directly
+*/
+type CarState struct {
+	Name      string
+	Bot       bool
+	Team      int
+	Health    int64
+	Bombs     int
+	Direction int
+	Speed     int64
+	Borders   Rectangle
+}
+
+// RoundSnapshot is what the bot knows about the round when it decides
+type RoundSnapshot struct {
+	// Index of the car driven by the bot in Cars
+	Me    int
+	Cars  []CarState
+	Bombs []Point
+	// The closest heart, {-1, -1} if there is no heart on the map
+	Bonus    Point
+	PowerUps []PowerUpState
+	State    int
+	// Cars must stay inside to not hit the walls
+	Arena Rectangle
+	// Walls inside the arena
+	Walls []Point
+	// Where the mode wants the car to go, {-1, -1} if anywhere
+	Goal Point
+}
+
+type PowerUpState struct {
+	Position Point
+	Kind     int
+}
+
+// BotAction is applied to the car of the bot. Direction -1 keeps the current one
+type BotAction struct {
+	Direction int
+	DropBomb  bool
+}
+
+/*
+BotBrain drives one car. Decide is called every Interval while the round
+is running. Brains may keep state between decisions, so every car gets its own
+*/
+type BotBrain interface {
+	Decide(s *RoundSnapshot) BotAction
+	Interval() time.Duration
+}
+
+var botLevels = map[string]func() BotBrain{
+	"easy": func() BotBrain {
+		return &hunterBrain{interval: 800 * time.Millisecond, bombFactor: 4 * highFactor, wanderFactor: 3}
+	},
+	"normal": func() BotBrain {
+		return &hunterBrain{interval: 500 * time.Millisecond, bombFactor: highFactor}
+	},
+	"hard": func() BotBrain {
+		return &hunterBrain{interval: 300 * time.Millisecond, bombFactor: highFactor, heartHealth: 50, predict: true, weakest: true, pathfinding: true}
+	},
+	"nightmare": func() BotBrain {
+		return &hunterBrain{interval: 150 * time.Millisecond, bombFactor: highFactor, heartHealth: 70, predict: true, weakest: true, bombChasers: true, pathfinding: true}
+	},
+}
+
+const defaultBotLevel = "normal"
+
+// Cost of the way to the heart which is always worth it
+const heartDetour = 30
+
+// Columns ahead of the target where the bot waits for it, found by tournaments
+const aheadOfTarget = 20
+
+// Built-in levels first, then scripts
+func newBotBrain(level string) (BotBrain, error) {
+	if newBrain, ok := botLevels[level]; ok {
+		return newBrain(), nil
+	}
+	if script, ok := loadedBotScripts()[level]; ok {
+		return &scriptBrain{script: script, memory: starlark.NewDict(0)}, nil
+	}
+	return nil, fmt.Errorf("Unknown bot level %q, known are %v", level, botLevelNames())
+}
+
+// Built-in levels, then scripts once they are loaded
+func botLevelNames() []string {
+	var names []string
+	for name := range botLevels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append(names, scriptNames()...)
+}
+
+func (round *Round) snapshot(me *Player) *RoundSnapshot {
+	s := &RoundSnapshot{Me: -1, Bonus: Point{-1, -1}, State: round.State, Goal: Point{-1, -1}, Arena: Rectangle{[4]Point{
+		{1, 1},
+		{mapWidth - nameTableWidth - 1, 1},
+		{mapWidth - nameTableWidth - 1, mapHeight - 2},
+		{1, mapHeight - 2}}},
+	}
+	for i := range round.Players {
+		p := &round.Players[i]
+		if p == me {
+			s.Me = i
+			if zone, ok := round.fence(&p.Car.Borders); ok {
+				s.Arena = zone
+			}
+			if mode, ok := round.Mode.(goalMode); ok {
+				if goal, ok := mode.Goal(round, i); ok {
+					s.Goal = goal
+				}
+			}
+		}
+		// Cars waiting for the respawn are out of the game for the others
+		health := p.Health
+		if p != me && p.respawning() {
+			health = 0
+		}
+		s.Cars = append(s.Cars, CarState{p.Name, p.Bot, p.Team, health, p.Bombs, p.Car.Direction, p.Car.Speed, p.Car.Borders})
+	}
+
+	s.Walls = sortedPoints(round.Walls)
+
+	round.Lock()
+	s.Bombs = sortedPoints(round.Bombs)
+	for _, p := range sortedPoints(round.PowerUps) {
+		s.PowerUps = append(s.PowerUps, PowerUpState{p, round.PowerUps[p]})
+	}
+	round.Unlock()
+	s.Bonus = s.closestHeart()
+	return s
+}
+
+func (s *RoundSnapshot) closestHeart() Point {
+	heart, distance := Point{-1, -1}, -1
+	if s.Me < 0 {
+		return heart
+	}
+	center := s.me().center()
+	for _, p := range s.PowerUps {
+		d := abs(p.Position.X-center.X) + verticalCost*abs(p.Position.Y-center.Y)
+		if p.Kind == HEART && (distance < 0 || d < distance) {
+			heart, distance = p.Position, d
+		}
+	}
+	return heart
+}
+
+func (player *Player) applyBotAction(action BotAction) {
+	if action.Direction >= LEFT && action.Direction <= DOWN {
+		player.Car.Direction = action.Direction
+	}
+	if action.DropBomb && player.Bombs > 0 {
+		player.DropBomb = true
+	}
+}
+
+func (s *RoundSnapshot) me() *CarState {
+	return &s.Cars[s.Me]
+}
+
+func (c *CarState) center() Point {
+	return Point{
+		c.Borders.Points[LEFTUP].X + (c.Borders.Points[RIGHTUP].X-c.Borders.Points[LEFTUP].X)/2,
+		c.Borders.Points[LEFTUP].Y + (c.Borders.Points[LEFTDOWN].Y-c.Borders.Points[LEFTUP].Y)/2,
+	}
+}
+
+func (s *RoundSnapshot) hasBonus() bool {
+	return s.Bonus.X != -1 && s.Bonus.Y != -1
+}
+
+func (s *RoundSnapshot) hasGoal() bool {
+	return s.Goal.X != -1 && s.Goal.Y != -1
+}
+
+/*
+Cars worth hunting: alive humans first, other cars if no humans are left.
+Teammates are never hunted
+*/
+func (s *RoundSnapshot) targets() []int {
+	var humans, cars []int
+	for i, c := range s.Cars {
+		if c.Team == s.me().Team || c.Health <= 0 {
+			continue
+		}
+		cars = append(cars, i)
+		if !c.Bot {
+			humans = append(humans, i)
+		}
+	}
+	if len(humans) > 0 {
+		return humans
+	}
+	return cars
+}
+
+/*
+Checks if the side is safe: no other car is next to it
+*/
+func (s *RoundSnapshot) okSide(side int) bool {
+	me := s.me()
+	for i := range s.Cars {
+		if i == s.Me {
+			continue
+		}
+		// If bot is next to someone from the side - return false
+		if side == me.Borders.nextTo(&s.Cars[i].Borders, 3) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Chooses the closest way to the target avoiding cars on the sides
+*/
+func (s *RoundSnapshot) steer(target Point) int {
+	me := s.me()
+	myCenter := me.center()
+	direction := me.Direction
+
+	if target.X < myCenter.X {
+		// Target is left from us
+		if direction != RIGHT && s.okSide(LEFT) {
+			return LEFT
+		} else if target.Y < myCenter.Y {
+			return UP
+		}
+		return DOWN
+	} else if target.X > myCenter.X {
+		// Target is right from us
+		if direction != LEFT && s.okSide(RIGHT) {
+			return RIGHT
+		} else if target.Y < myCenter.Y {
+			return UP
+		}
+		return DOWN
+	} else if target.Y < myCenter.Y && s.okSide(UP) {
+		// Target is above us
+		if direction != DOWN {
+			return UP
+		} else if target.X > myCenter.X {
+			return RIGHT
+		}
+		return LEFT
+	} else if target.Y > myCenter.Y && s.okSide(DOWN) {
+		// Target it below us
+		if direction != UP {
+			return DOWN
+		} else if target.X > myCenter.X {
+			return RIGHT
+		}
+		return LEFT
+	}
+	return -1
+}
+
+/*
+Where the car will be after it moves the amount of columns. Vertical moves
+are 3x slower, so the car passes 3x less rows. The car can't leave the arena
+*/
+func (c *CarState) predictCenter(arena *Rectangle, columns int) Point {
+	center := c.center()
+	switch c.Direction {
+	case LEFT:
+		center.X -= columns
+	case RIGHT:
+		center.X += columns
+	case UP:
+		center.Y -= columns / verticalCost
+	case DOWN:
+		center.Y += columns / verticalCost
+	}
+	center.X = max(arena.Points[LEFTUP].X, min(center.X, arena.Points[RIGHTDOWN].X))
+	center.Y = max(arena.Points[LEFTUP].Y, min(center.Y, arena.Points[RIGHTDOWN].Y))
+	return center
+}
+
+/*
+hunterBrain chases a car and grabs the heart on the way.
+Levels differ in reaction time, aiming and the use of bombs
+*/
+type hunterBrain struct {
+	interval time.Duration
+	// Chance 1 of N to drop a bomb on each decision, 0 never
+	bombFactor int
+	// Chance 1 of N to go to a random direction instead of the target, 0 never
+	wanderFactor int
+	// Go for the heart anywhere on the map below this health, otherwise only if it is close
+	heartHealth int64
+	// Aim at the place where the target will be
+	predict bool
+	// Hunt the weakest target instead of a random one
+	weakest bool
+	// Drop bombs when someone is right behind
+	bombChasers bool
+	// Find the way around bombs, wrecks and walls instead of driving straight
+	pathfinding bool
+
+	target        string
+	decisionsLeft int
+}
+
+func (b *hunterBrain) Interval() time.Duration {
+	return b.interval
+}
+
+func (b *hunterBrain) Decide(s *RoundSnapshot) BotAction {
+	action := BotAction{Direction: -1}
+	me := s.me()
+
+	if b.bombFactor > 0 && rand.Intn(b.bombFactor) == 0 {
+		action.DropBomb = true
+	}
+	if b.bombChasers && s.chased() {
+		action.DropBomb = true
+	}
+	if b.wanderFactor > 0 && rand.Intn(b.wanderFactor) == 0 {
+		action.Direction = rand.Intn(4)
+		return action
+	}
+
+	if b.pathfinding {
+		return b.decidePath(s, action)
+	}
+
+	// Bot prefers to grab the heart
+	heartRect := &Rectangle{Points: [4]Point{s.Bonus, s.Bonus, s.Bonus, s.Bonus}}
+	if s.hasBonus() && (me.Borders.nextTo(heartRect, 5) != -1 || me.Health < b.heartHealth) {
+		action.Direction = s.steer(s.Bonus)
+		return action
+	}
+
+	if s.hasGoal() {
+		action.Direction = s.steer(s.Goal)
+		return action
+	}
+
+	target := b.chooseTarget(s)
+	if target == nil {
+		return action
+	}
+	aim := target.center()
+	if b.predict {
+		aim = target.predictCenter(&s.Arena, verticalCost*int(target.Speed))
+	}
+	action.Direction = s.steer(aim)
+	return action
+}
+
+/*
+Same as Decide, but follows the shortest way. The heart is taken when it is
+close or the health is low. Ramming hurts the car which moves into another
+one, so the bot never does it: it goes ahead of the target instead and lets
+the target run into the car or the bombs
+*/
+func (b *hunterBrain) decidePath(s *RoundSnapshot, action BotAction) BotAction {
+	me := s.me()
+	finder := newPathFinder(s)
+
+	if s.hasBonus() {
+		direction, cost := finder.find(me, s.Bonus)
+		if direction != -1 && (cost <= heartDetour || me.Health < b.heartHealth) {
+			action.Direction = direction
+			return action
+		}
+	}
+
+	if s.hasGoal() {
+		if action.Direction, _ = finder.find(me, s.Goal); action.Direction == -1 {
+			action.Direction = s.steer(s.Goal)
+		}
+		return action
+	}
+
+	target := b.chooseTarget(s)
+	if target == nil {
+		return action
+	}
+	aim := target.center()
+	if b.predict {
+		aim = target.predictCenter(&s.Arena, aheadOfTarget)
+	}
+	if action.Direction, _ = finder.find(me, aim); action.Direction == -1 {
+		// No way around, hope for the best
+		action.Direction = s.steer(aim)
+	}
+	return action
+}
+
+/*
+Keeps the same target for 10 seconds like the original bots did
+*/
+func (b *hunterBrain) chooseTarget(s *RoundSnapshot) *CarState {
+	for i := range s.Cars {
+		if s.Cars[i].Name == b.target && s.Cars[i].Health > 0 && i != s.Me && b.decisionsLeft > 0 {
+			b.decisionsLeft--
+			return &s.Cars[i]
+		}
+	}
+
+	targets := s.targets()
+	if len(targets) == 0 {
+		return nil
+	}
+	chosen := targets[rand.Intn(len(targets))]
+	if b.weakest {
+		for _, i := range targets {
+			if s.Cars[i].Health < s.Cars[chosen].Health {
+				chosen = i
+			}
+		}
+	}
+	b.target = s.Cars[chosen].Name
+	b.decisionsLeft = int(10 * time.Second / b.interval)
+	return &s.Cars[chosen]
+}
+
+// Checks if another car is right behind the bot
+func (s *RoundSnapshot) chased() bool {
+	me := s.me()
+	behind := LEFT
+	switch me.Direction {
+	case LEFT:
+		behind = RIGHT
+	case UP:
+		behind = DOWN
+	case DOWN:
+		behind = UP
+	}
+
+	for i := range s.Cars {
+		if s.Cars[i].Team != me.Team && s.Cars[i].Health > 0 && me.Borders.nextTo(&s.Cars[i].Borders, 10) == behind {
+			return true
+		}
+	}
+	return false
+}
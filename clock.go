@@ -0,0 +1,9 @@
+package main
+
+import "time"
+
+/*
+Game mechanics read the time through now, so the tournament can play
+rounds on a simulated clock much faster than the real one
+*/
+var now = time.Now
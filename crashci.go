@@ -5,14 +5,17 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/rand"
 	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const framesPerSecond = 8
@@ -24,6 +27,7 @@ const minPlayersPerRound = 1
 const maxRoundWaitingTimeSec = 5
 const maxRoundRunningTimeSec = 600
 const maxSpeed = 5
+const broadcastDuration = 5 * time.Second
 
 const bonusPoint = 5
 const lowFactor = 50
@@ -37,10 +41,15 @@ const horizontalCarHeight = 3
 const verticalCarWidth = 5
 const verticalCarHeight = 3
 
+const defaultAcidPath = "/Users/leoleovich/go/src/github.com/leoleovich/crashci/artifacts"
+
 const colorPrefix = "\x1b["
 const colorPostfix = "m"
 const bonus = "\xE2\x99\xA5"
 const bomb = "\xE2\x9C\xB3"
+const wall = "\xE2\x96\x88"
+
+var errCommunication = errors.New("Communication error")
 
 var (
 	cars = [4][]byte{}
@@ -50,6 +59,9 @@ var (
 	// [14A[100D
 	middle = []byte{27, 91, 49, 52, 65, 27, 91, 57, 52, 68}
 	conf   Config
+	// Changed at runtime by the admin console
+	botsPerRound         int64
+	defaultRoundBotLevel atomic.Value
 )
 
 // States of the round
@@ -61,6 +73,14 @@ const (
 	FINISHED
 )
 
+var stateNames = map[int]string{
+	COMPILING: "compiling",
+	WAITING:   "waiting",
+	STARTING:  "starting",
+	RUNNING:   "running",
+	FINISHED:  "finished",
+}
+
 // Directions
 const (
 	LEFT = 0 + iota
@@ -86,18 +106,45 @@ const (
 
 // Colors
 const (
-	RESET = 0
-	BOLD  = 1
-	RED   = 31
-	GREEN = 32
-	//YELLOW  = 33
-	//BLUE    = 34
+	RESET  = 0
+	BOLD   = 1
+	DIM    = 2
+	RED    = 31
+	GREEN  = 32
+	YELLOW = 33
+	BLUE   = 34
 	//MAGENTA = 35
+	CYAN = 36
 )
 
 type Config struct {
-	Log      *log.Logger
-	AcidPath string
+	Log            *slog.Logger
+	AcidPath       string
+	NameTimeout    time.Duration
+	Limiter        *connLimiter
+	Bans           *banList
+	Blocklist      *nameBlocklist
+	AfkTimeout     time.Duration
+	AfkAction      string
+	ReconnectGrace time.Duration
+	JoinRunning    bool
+	JoinWindow     time.Duration
+	Teams          int
+	FriendlyFire   bool
+	Mode           string
+	CtfScore       int
+	Track          string
+	RaceLaps       int
+	RaceBombs      bool
+	LapRecords     *lapRecords
+	Lives          int
+	RespawnDelay   time.Duration
+	Invulnerable   time.Duration
+	LivesTime      time.Duration
+	ShrinkAfter    time.Duration
+	ShrinkInterval time.Duration
+	HillScore      int
+	PowerUpWeights [powerUpKinds]int
 }
 
 type Point struct {
@@ -114,14 +161,14 @@ type Symbols []Symbol
 func getAcid(fileName string) ([]byte, error) {
 	fileStat, err := os.Stat(conf.AcidPath + "/" + fileName)
 	if err != nil {
-		conf.Log.Printf("Acid %s does not exist: %v\n", fileName, err)
+		conf.Log.Error("Acid does not exist", "file", fileName, "err", err)
 		return []byte{}, err
 	}
 
 	acid := make([]byte, fileStat.Size())
 	f, err := os.OpenFile(conf.AcidPath+"/"+fileName, os.O_RDONLY, os.ModePerm)
 	if err != nil {
-		conf.Log.Printf("Error while opening %s: %v\n", fileName, err)
+		conf.Log.Error("Error while opening acid", "file", fileName, "err", err)
 		os.Exit(1)
 	}
 	defer f.Close()
@@ -135,56 +182,60 @@ func getPlayerData(conn net.Conn, splash []byte) (Player, error) {
 	// Get data of player and return the structure
 	_, err := conn.Write(clear)
 	if err != nil {
-		return Player{}, errors.New("Communication error")
+		return Player{}, errCommunication
 	}
 	_, err = conn.Write(home)
 	if err != nil {
-		return Player{}, errors.New("Communication error")
+		return Player{}, errCommunication
 	}
 	_, err = conn.Write(splash)
 	if err != nil {
-		return Player{}, errors.New("Communication error")
+		return Player{}, errCommunication
 	}
 	_, err = conn.Write(middle)
 	if err != nil {
-		return Player{}, errors.New("Communication error")
+		return Player{}, errCommunication
 	}
 
 	io := bufio.NewReader(conn)
 
+	// Do not let clients hold the socket forever without a name
+	if conf.NameTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(conf.NameTimeout))
+		defer conn.SetReadDeadline(time.Time{})
+	}
 	line, err := io.ReadString('\n')
 	if err != nil {
-		return Player{}, errors.New("Communication error")
+		return Player{}, fmt.Errorf("%w: %w", errCommunication, err)
 	}
 
-	name := strings.Replace(strings.Replace(line, "\n", "", -1), "\r", "", -1)
-	if name == "" {
-		return Player{}, errors.New("Empty name")
+	// Telnet clients may negotiate options before the name is entered
+	name := strings.TrimSpace(string(stripTelnetCommands([]byte(strings.TrimRight(line, "\r\n\x00")))))
+	if err := validateName(name); err != nil {
+		return Player{}, err
 	}
-	if len(name) > maxNameLength {
-		return Player{}, errors.New("Too long name")
+	if err := checkNameAllowed(name); err != nil {
+		return Player{}, err
 	}
 
-	return Player{Conn: conn, Name: name, Health: 100, Car: Car{Speed: 1}}, nil
+	return Player{Conn: conn, Name: name, Health: 100, Token: newResumeToken(), Car: Car{Speed: 1}}, nil
 }
 
-func checkRoundReady(compileRoundChannel, runningRoundChannel chan Round) {
+func checkRoundReady(compileRoundChannel, runningRoundChannel chan *Round) {
 	for {
-		fmt.Println("compile/waiting rounds:", len(compileRoundChannel))
+		conf.Log.Debug("Checking compile/waiting rounds", "rounds", len(compileRoundChannel))
 		r := <-compileRoundChannel
-		fmt.Println(r.Id, "players in round:", len(r.Players))
+		r.logger().Debug("Checking round", "players", len(r.Players))
 
-		if len(r.Players) == maxPlayersPerRound ||
+		if len(r.Players) == r.MaxPlayers ||
 			(r.State == WAITING && r.LastStateChange.Add(maxRoundWaitingTimeSec*time.Second).Before(time.Now())) {
 			// We are starting round if it is fully booked or waiting time is expired
-			fmt.Println(r.Id, "Round has changed to the state STARTING")
-			r.State = STARTING
+			r.setState(STARTING)
 			r.LastStateChange = time.Now()
 			runningRoundChannel <- r
 		} else if len(r.Players) >= minPlayersPerRound {
 			if r.State == COMPILING {
-				fmt.Println(r.Id, "Round has changed to the state WAITING")
-				r.State = WAITING
+				r.setState(WAITING)
 				r.LastStateChange = time.Now()
 			} else if r.State == WAITING {
 				r.writeToAllPlayers([]byte(fmt.Sprintf(
@@ -200,16 +251,21 @@ func checkRoundReady(compileRoundChannel, runningRoundChannel chan Round) {
 	}
 }
 
-func checkRoundRun(runningRoundChannel chan Round) {
+func checkRoundRun(runningRoundChannel chan *Round) {
 	for {
 		round := <-runningRoundChannel
 		if len(round.Players) > 0 {
-			for len(round.Players) < maxPlayersPerRound {
+			for bots := int64(0); len(round.Players) < round.MaxPlayers && bots < atomic.LoadInt64(&botsPerRound); bots++ {
 				p := round.generateBot()
-				p.initPlayer(len(round.Players))
+				p.initPlayer(len(round.Players), round.Teams)
 				round.Players = append(round.Players, p)
+				botsCount.Inc()
 			}
+			roundPlayers.Observe(float64(round.humans()))
+			atomic.AddInt64(&totalRounds, 1)
 			go round.start()
+		} else {
+			registry.remove(round.Id)
 		}
 	}
 }
@@ -229,40 +285,298 @@ func (symbols Symbols) symbolsToByte() []byte {
 	return returnSlice
 }
 
-func prepare(conn net.Conn, splash []byte, compileRoundChannel chan Round) {
+func prepare(conn net.Conn, splash []byte, compileRoundChannel chan *Round) {
+	if conf.Bans.bannedIP(ipOf(conn)) {
+		conf.Log.Info("Banned address rejected", "addr", addrOf(conn))
+		reject(conn, "Sorry, your address is banned.")
+		disconnect(conn, reasonBanned)
+		return
+	}
+
 	p, err := getPlayerData(conn, splash)
-	if err != nil {
-		conn.Close()
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		conn.Write([]byte("\r\nTime to enter the name is over, bye!\r\n"))
+		disconnect(conn, reasonTimeout)
+		return
+	} else if err != nil {
+		conf.Log.Info("Name rejected", "event", eventNameRejected, "addr", addrOf(conn), "err", err)
+		if !errors.Is(err, errCommunication) {
+			reject(conn, "Sorry, you can't play with this name: "+err.Error()+".")
+		}
+		disconnect(conn, reasonBadName)
+		return
+	}
+	conf.Log.Info("Name accepted", "event", eventNameAccepted, "addr", addrOf(conn), "player", p.Name)
+	annotateConn(conn, "player", p.Name)
+
+	if round, player := findDisconnectedPlayer(p.Name); player != nil {
+		if err := resume(conn, round, player); err != nil {
+			conf.Log.Info("Failed to resume", "addr", addrOf(conn), "player", p.Name, "err", err)
+			if errors.Is(err, errWrongToken) {
+				reject(conn, "Sorry, the resume token is wrong. Try again or wait till the round is over.")
+			} else if errors.Is(err, errResumeTooLate) {
+				reject(conn, "Sorry, your car is already given to the bot. Wait till the round is over.")
+			}
+			disconnect(conn, reasonBadToken)
+		}
 		return
 	}
 
 	p.checkBestRoundForPlayer(compileRoundChannel)
 }
 
+func serveTelnet(l net.Listener, proxyProtocol bool, splash []byte, compileRoundChannel chan *Round) {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			conf.Log.Error("Failed to accept request", "err", err)
+			continue
+		}
+
+		go handleTelnet(conn, proxyProtocol, splash, compileRoundChannel)
+	}
+}
+
+func handleTelnet(conn net.Conn, proxyProtocol bool, splash []byte, compileRoundChannel chan *Round) {
+	if proxyProtocol {
+		proxied, err := readProxyHeader(conn)
+		if err != nil {
+			conf.Log.Warn("Failed to read PROXY header", "proxy", addrOf(conn), "err", err)
+			conn.Close()
+			return
+		}
+		conn = proxied
+	}
+
+	limited, reason := conf.Limiter.limit(conn)
+	if reason != "" {
+		conf.Log.Info("Connection rejected", "addr", addrOf(conn), "reason", reason)
+		rejects.WithLabelValues(reason).Inc()
+		reject(conn, rejectMessages[reason])
+		conn.Close()
+		return
+	}
+	conn = limited
+
+	users := atomic.AddInt64(&totalConnections, 1)
+	conf.Log.Info("Client connected", "event", eventConnect, "addr", addrOf(conn), "total", users)
+
+	prepare(newMeteredConn(conn), splash, compileRoundChannel)
+}
+
+func serveHTTP(listeners []net.Listener, handler http.Handler) {
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			conf.Log.Error("HTTP server stopped", "addr", l.Addr().String(), "err", http.Serve(l, handler))
+		}(l)
+	}
+}
+
+func mustListen(name, addresses string, private bool) []net.Listener {
+	listeners, err := listenAll(addresses, private)
+	if err != nil {
+		conf.Log.Error("Failed to listen", "listener", name, "addresses", addresses, "err", err)
+		os.Exit(2)
+	}
+	for _, l := range listeners {
+		conf.Log.Info("Listening", "listener", name, "addr", l.Addr().String())
+	}
+	return listeners
+}
+
 func main() {
-	//Enable profile
-	go func() {
-		log.Println(http.ListenAndServe("localhost:6060", nil))
-	}()
+	if len(os.Args) > 1 && os.Args[1] == "tournament" {
+		os.Exit(tournament(os.Args[2:]))
+	}
 
 	// Make random unique
 	rand.Seed(time.Now().Unix())
-	var logFile, acidPath string
-	var port, users int
+	var logFile, logFormat, logLevel, acidPath, apiToken, bansFile, blocklistFile, afkAction, botLevel, mode, track, lapsFile, powerUpWeights, adminToken string
+	var telnetListen, httpListen, metricsListen, adminListen, botListen string
+	var port, maxConns, maxConnsPerIP, connBurst, teams, ctfScore, raceLaps, lives, hillScore int
+	var connRate float64
+	var nameTimeout, afkTimeout, reconnectGrace, joinWindow, respawnDelay, invulnerable, livesTime, shrinkAfter, shrinkInterval time.Duration
+	var proxyProtocol, joinRunning, friendlyFire, raceBombs bool
 
 	flag.StringVar(&logFile, "l", "/var/log/race.log", "Log file")
-	flag.IntVar(&port, "p", 4242, "Port to listen")
-	flag.StringVar(&acidPath, "a", "/Users/leoleovich/go/src/github.com/leoleovich/crashci/artifacts", "Artifacts location")
+	flag.StringVar(&logFormat, "log-format", "logfmt", "Log format: logfmt or json")
+	flag.StringVar(&logLevel, "log-level", "info", "Log level: debug, info, warn or error")
+	flag.IntVar(&port, "p", 4242, "Port to listen, used if -listen is empty")
+	flag.StringVar(&telnetListen, "listen", "", "Comma separated telnet addresses: host:port, [ipv6]:port or unix:/path")
+	flag.BoolVar(&proxyProtocol, "proxy-protocol", false, "Expect PROXY protocol v1 or v2 header on telnet connections")
+	flag.StringVar(&httpListen, "http-listen", "localhost:6060", "Comma separated addresses for pprof, API and metrics")
+	flag.StringVar(&metricsListen, "metrics-listen", "", "Comma separated addresses to serve metrics separately from -http-listen")
+	flag.StringVar(&adminListen, "admin-listen", "", "Comma separated addresses for the admin console, disabled if empty")
+	flag.StringVar(&botListen, "bot-listen", "", "Comma separated addresses for external bots speaking JSON, disabled if empty")
+	flag.IntVar(&maxConns, "max-conns", 1000, "Maximum amount of telnet connections, 0 is unlimited")
+	flag.IntVar(&maxConnsPerIP, "max-conns-per-ip", 10, "Maximum amount of telnet connections from one address, 0 is unlimited")
+	flag.Float64Var(&connRate, "conn-rate", 30, "Maximum rate of new connections per minute from one address, 0 is unlimited")
+	flag.IntVar(&connBurst, "conn-burst", 10, "Amount of connections from one address allowed at once before -conn-rate applies")
+	flag.DurationVar(&nameTimeout, "name-timeout", time.Minute, "Time to enter the name, 0 is unlimited")
+	flag.DurationVar(&afkTimeout, "afk-timeout", time.Minute, "Time without input in a running round before the player is warned, 0 disables AFK detection")
+	flag.StringVar(&afkAction, "afk-action", AFK_BOT, "What to do with AFK players: bot or kick")
+	flag.DurationVar(&reconnectGrace, "reconnect-grace", time.Minute, "Time to reconnect to a running round before the car is given to a bot, 0 disables reconnects")
+	flag.BoolVar(&joinRunning, "join-running", true, "Let new players take over bots in running rounds")
+	flag.DurationVar(&joinWindow, "join-window", 0, "Take over bots only in rounds running not longer than this, 0 is the whole round")
+	flag.StringVar(&bansFile, "bans", "", "File with banned IPs and names like /var/lib/crashci/bans.json, not persisted if empty")
+	flag.StringVar(&blocklistFile, "name-blocklist", "", "File with words forbidden in names, one per line")
+	flag.StringVar(&apiToken, "api-token", "", "Token for the HTTP API actions, disabled if empty")
+	flag.StringVar(&adminToken, "admin-token", "", "Token asked by the admin console, required to listen it on non-loopback addresses")
+	flag.Int64Var(&botsPerRound, "bots", maxPlayersPerRound-1, "Maximum amount of bots added to a round")
+	flag.StringVar(&botLevel, "bot-level", defaultBotLevel, fmt.Sprintf("Level of bots in new rounds: %s or the name of a script in bots/ of the artifacts", strings.Join(botLevelNames(), ", ")))
+	flag.IntVar(&teams, "teams", 0, fmt.Sprintf("Amount of teams in new rounds, 2-%d, 0 is free-for-all", maxTeams))
+	flag.BoolVar(&friendlyFire, "friendly-fire", false, "Let teammates hurt each other with crashes and bombs")
+	flag.StringVar(&mode, "mode", defaultGameMode, fmt.Sprintf("Game mode of new rounds: %s", strings.Join(gameModeNames(), ", ")))
+	flag.IntVar(&ctfScore, "ctf-score", 3, "Captured flags needed to win a capture-the-flag round")
+	flag.StringVar(&track, "track", "oval", "Track of race rounds from the tracks directory of the artifacts")
+	flag.IntVar(&raceLaps, "laps", 3, "Laps of race rounds")
+	flag.BoolVar(&raceBombs, "race-bombs", false, "Let cars drop bombs in race rounds")
+	flag.IntVar(&lives, "lives", 3, "Lives of every car in lives rounds")
+	flag.DurationVar(&respawnDelay, "respawn-delay", 3*time.Second, "Time before an eliminated car comes back in lives rounds")
+	flag.DurationVar(&invulnerable, "invulnerability", 3*time.Second, "Time a respawned car can't be hurt")
+	flag.DurationVar(&livesTime, "lives-time", 5*time.Minute, "Duration of lives rounds, the most eliminations win then")
+	flag.DurationVar(&shrinkAfter, "shrink-after", time.Minute, "Time before the arena starts shrinking in shrink rounds")
+	flag.DurationVar(&shrinkInterval, "shrink-interval", 5*time.Second, "Time between the steps of the shrinking arena")
+	flag.IntVar(&hillScore, "hill-score", 60, "Seconds on the hill needed to win king-of-the-hill rounds")
+	flag.StringVar(&powerUpWeights, "powerups", defaultPowerUpWeights, fmt.Sprintf("Spawn weights of power-ups, unlisted never spawn: %s", strings.Join(powerUpNames(), ", ")))
+	flag.StringVar(&lapsFile, "laps-file", "/var/lib/crashci/laps.json", "File with the best laps of time trials, not persisted if empty")
+	flag.StringVar(&acidPath, "a", defaultAcidPath, "Artifacts location")
 	flag.Parse()
 
 	logfile, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
-	conf = Config{log.New(logfile, "", log.Ldate|log.Lmicroseconds|log.Lshortfile), acidPath}
-
-	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
-		os.Exit(2)
+		fmt.Fprintln(os.Stderr, "Failed to open log file, logging to stderr:", err)
+		logfile = os.Stderr
+	}
+	logger, err := newLogger(logfile, logFormat, logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	scripts, err := loadBotScripts(acidPath)
+	if err != nil {
+		logger.Error("Failed to load bot scripts", "err", err)
+	}
+	botScripts.Store(scripts)
+	if raceTracks, err = loadTracks(acidPath); err != nil {
+		logger.Error("Failed to load race tracks", "err", err)
+	}
+	if _, err := newBotBrain(botLevel); err != nil {
+		logger.Error("Bad bot level", "err", err)
+		os.Exit(1)
+	}
+	defaultRoundBotLevel.Store(botLevel)
+	if afkAction != AFK_BOT && afkAction != AFK_KICK {
+		logger.Error("Unknown AFK action", "action", afkAction)
+		os.Exit(1)
+	}
+	if err := validateTeams(teams); err != nil {
+		logger.Error("Bad amount of teams", "err", err)
+		os.Exit(1)
+	}
+	if err := validateGameMode(mode, teams, track); err != nil {
+		logger.Error("Bad game mode", "err", err)
+		os.Exit(1)
+	}
+	if ctfScore < 1 {
+		logger.Error("Captured flags needed to win must be positive", "ctf-score", ctfScore)
+		os.Exit(1)
+	}
+	if raceLaps < 1 {
+		logger.Error("Laps must be positive", "laps", raceLaps)
+		os.Exit(1)
+	}
+	if lives < 1 {
+		logger.Error("Lives must be positive", "lives", lives)
+		os.Exit(1)
+	}
+	if shrinkInterval <= 0 {
+		logger.Error("Shrink interval must be positive", "shrink-interval", shrinkInterval)
+		os.Exit(1)
+	}
+	if hillScore < 1 {
+		logger.Error("Seconds on the hill needed to win must be positive", "hill-score", hillScore)
+		os.Exit(1)
+	}
+	weights, err := parsePowerUpWeights(powerUpWeights)
+	if err != nil {
+		logger.Error("Bad power-up weights", "err", err)
+		os.Exit(1)
+	}
+	bans, err := loadBans(bansFile)
+	if err != nil {
+		logger.Error("Failed to load bans", "file", bansFile, "err", err)
+		os.Exit(1)
+	}
+	lapRecords, err := loadLapRecords(lapsFile)
+	if err != nil {
+		logger.Error("Failed to load lap records", "file", lapsFile, "err", err)
+		os.Exit(1)
+	}
+	blocklist, err := newNameBlocklist(blocklistFile)
+	if err != nil {
+		logger.Error("Failed to load name blocklist", "file", blocklistFile, "err", err)
+		os.Exit(1)
+	}
+	conf = Config{
+		Log:            logger,
+		AcidPath:       acidPath,
+		NameTimeout:    nameTimeout,
+		Limiter:        newConnLimiter(maxConns, maxConnsPerIP, connRate, connBurst),
+		Bans:           bans,
+		Blocklist:      blocklist,
+		AfkTimeout:     afkTimeout,
+		AfkAction:      afkAction,
+		ReconnectGrace: reconnectGrace,
+		JoinRunning:    joinRunning,
+		JoinWindow:     joinWindow,
+		Teams:          teams,
+		FriendlyFire:   friendlyFire,
+		Mode:           mode,
+		CtfScore:       ctfScore,
+		Track:          track,
+		RaceLaps:       raceLaps,
+		RaceBombs:      raceBombs,
+		LapRecords:     lapRecords,
+		Lives:          lives,
+		RespawnDelay:   respawnDelay,
+		Invulnerable:   invulnerable,
+		LivesTime:      livesTime,
+		ShrinkAfter:    shrinkAfter,
+		ShrinkInterval: shrinkInterval,
+		HillScore:      hillScore,
+		PowerUpWeights: weights,
+	}
+
+	if telnetListen == "" {
+		telnetListen = fmt.Sprintf(":%d", port)
+	}
+	telnetListeners := mustListen("telnet", telnetListen, false)
+	httpListeners := mustListen("http", httpListen, false)
+	metricsListeners := mustListen("metrics", metricsListen, false)
+	adminListeners := mustListen("admin", adminListen, true)
+	botListeners := mustListen("bot", botListen, false)
+
+	//Enable profile, metrics and API
+	registerAPI(http.DefaultServeMux, apiToken)
+	if len(metricsListeners) > 0 {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", promhttp.Handler())
+		serveHTTP(metricsListeners, metricsMux)
+	} else {
+		http.Handle("/metrics", promhttp.Handler())
+	}
+	serveHTTP(httpListeners, http.DefaultServeMux)
+
+	for _, l := range adminListeners {
+		if err := restrictAdmin(l, adminToken); err != nil {
+			conf.Log.Error("Failed to restrict admin listener", "addr", l.Addr().String(), "err", err)
+			os.Exit(2)
+		}
+		go serveAdmin(l, adminToken)
 	}
-	defer l.Close()
 
 	// Read sketches
 	cars[LEFT], _ = getAcid("carLeft.txt")
@@ -271,20 +585,17 @@ func main() {
 	cars[DOWN], _ = getAcid("carDown.txt")
 	splash, _ := getAcid("splash.txt")
 
-	compileRoundChannel := make(chan Round, maxParallelRounds)
-	runningRoundChannel := make(chan Round, maxParallelRounds)
+	compileRoundChannel := make(chan *Round, maxParallelRounds)
+	runningRoundChannel := make(chan *Round, maxParallelRounds)
 
 	go checkRoundReady(compileRoundChannel, runningRoundChannel)
 	go checkRoundRun(runningRoundChannel)
 
-	for {
-		conn, err := l.Accept()
-		users++
-		fmt.Println("In total", users, "users connected")
-		if err != nil {
-			conf.Log.Println("Failed to accept request", err)
-		}
-
-		go prepare(conn, splash, compileRoundChannel)
+	for _, l := range telnetListeners {
+		go serveTelnet(l, proxyProtocol, splash, compileRoundChannel)
+	}
+	for _, l := range botListeners {
+		go serveBots(l, compileRoundChannel)
 	}
+	select {}
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+)
+
+/*
+Capture the flag: every team has a base with its flag. Cars pick up flags
+of other teams by driving over them and score by bringing them to their own
+base. The flag is dropped where the carrier dies and goes back to its base
+when a car of its team drives over it
+*/
+
+const flagGlyph = "\xE2\x9A\x91"
+const baseGlyph = "\xC2\xB7"
+
+const ctfBaseWidth = 11
+const ctfBaseHeight = 5
+
+type ctfFlag struct {
+	Base     Rectangle
+	Position Point
+	// Index of the car which carries the flag, -1 if nobody does
+	Carrier int
+}
+
+type ctfMode struct {
+	scoreLimit int
+	flags      []ctfFlag
+	captures   []int
+}
+
+/*
+Bases are in the middle of the sides of the arena: left and right
+for the first two teams, top and bottom for the rest
+*/
+func ctfBase(team int) Rectangle {
+	arenaWidth := mapWidth - nameTableWidth
+	switch team {
+	case 0:
+		return newRectangle(3, mapHeight/2-ctfBaseHeight/2, ctfBaseWidth, ctfBaseHeight)
+	case 1:
+		return newRectangle(arenaWidth-3-ctfBaseWidth, mapHeight/2-ctfBaseHeight/2, ctfBaseWidth, ctfBaseHeight)
+	case 2:
+		return newRectangle(arenaWidth/2-ctfBaseWidth/2, 3, ctfBaseWidth, ctfBaseHeight)
+	}
+	return newRectangle(arenaWidth/2-ctfBaseWidth/2, mapHeight-4-ctfBaseHeight, ctfBaseWidth, ctfBaseHeight)
+}
+
+func (m *ctfMode) Start(round *Round) {
+	for team := 0; team < round.Teams; team++ {
+		base := ctfBase(team)
+		m.flags = append(m.flags, ctfFlag{Base: base, Position: base.center(), Carrier: -1})
+		m.captures = append(m.captures, 0)
+	}
+}
+
+func (m *ctfMode) Apply(round *Round, activeFrameBuffer []Symbol) {
+	if round.State == RUNNING {
+		for team := range m.flags {
+			m.play(round, team)
+		}
+	}
+
+	for team, flag := range m.flags {
+		drawWhereEmpty(activeFrameBuffer, flag.Base.edge(), Symbol{teamColors[team], []byte(baseGlyph)})
+		activeFrameBuffer[flag.Position.Y*mapWidth+flag.Position.X] = Symbol{teamColors[team], []byte(flagGlyph)}
+
+		alive, _ := round.teamStatus(team)
+		round.applyTeamScore(activeFrameBuffer, team, fmt.Sprintf("%d/%d flags, %d alive", m.captures[team], m.scoreLimit, alive))
+	}
+}
+
+func (m *ctfMode) play(round *Round, team int) {
+	flag := &m.flags[team]
+
+	if flag.Carrier != -1 {
+		carrier := &round.Players[flag.Carrier]
+		if carrier.Health <= 0 {
+			flag.Carrier = -1
+			return
+		}
+		flag.Position = carrier.Car.Borders.center()
+
+		base := m.flags[carrier.Team].Base
+		if carrier.Car.Borders.intersects(&base) {
+			m.captures[carrier.Team]++
+			round.broadcast(fmt.Sprintf("%s captured the %s flag!", carrier.displayName(flag.Carrier), teamNames[team]))
+			round.logger().Info("Flag captured", "player", carrier.Name, "flag", teamNames[team], "team", teamNames[carrier.Team])
+			flag.Carrier = -1
+			flag.Position = flag.Base.center()
+		}
+		return
+	}
+
+	flagRect := newRectangle(flag.Position.X, flag.Position.Y, 1, 1)
+	for num := range round.Players {
+		p := &round.Players[num]
+		if p.Health <= 0 || !p.Car.Borders.intersects(&flagRect) {
+			continue
+		}
+
+		if p.Team != team {
+			flag.Carrier = num
+			round.broadcast(fmt.Sprintf("%s took the %s flag!", p.displayName(num), teamNames[team]))
+		} else if flag.Position != flag.Base.center() {
+			flag.Position = flag.Base.center()
+		}
+		return
+	}
+}
+
+func (m *ctfMode) Winner(round *Round) string {
+	for team, captures := range m.captures {
+		if captures >= m.scoreLimit {
+			return teamTitle(team)
+		}
+	}
+	return round.lastTeamStanding()
+}
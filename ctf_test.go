@@ -0,0 +1,78 @@
+package main
+
+import "testing"
+
+func TestCtfFlags(t *testing.T) {
+	base0, base1 := ctfBase(0), ctfBase(1)
+	away := Point{60, 20}
+	carCenter := Point{away.X + horizontalCarWidth/2, away.Y + horizontalCarHeight/2}
+	tests := []struct {
+		name     string
+		setup    func(round *Round, flag *ctfFlag)
+		carrier  int
+		position Point
+		captures int
+	}{
+		{"lying at the base", func(round *Round, flag *ctfFlag) {}, -1, base1.center(), 0},
+		{"taken", func(round *Round, flag *ctfFlag) {
+			driveTo(round, 0, flag.Position)
+		}, 0, base1.center(), 0},
+		{"not taken by the wreck", func(round *Round, flag *ctfFlag) {
+			driveTo(round, 0, flag.Position)
+			round.Players[0].Health = 0
+		}, -1, base1.center(), 0},
+		{"own team passes by", func(round *Round, flag *ctfFlag) {
+			driveTo(round, 1, flag.Position)
+		}, -1, base1.center(), 0},
+		{"carried", func(round *Round, flag *ctfFlag) {
+			flag.Carrier = 0
+			driveTo(round, 0, away)
+		}, 0, carCenter, 0},
+		{"dropped", func(round *Round, flag *ctfFlag) {
+			flag.Carrier, flag.Position = 0, away
+			round.Players[0].Health = 0
+		}, -1, away, 0},
+		{"returned", func(round *Round, flag *ctfFlag) {
+			flag.Position = away
+			driveTo(round, 1, away)
+		}, -1, base1.center(), 0},
+		{"captured", func(round *Round, flag *ctfFlag) {
+			flag.Carrier = 0
+			driveTo(round, 0, base0.Points[LEFTUP])
+		}, -1, base1.center(), 1},
+	}
+	for _, test := range tests {
+		round := testRound(2, testCar{100, 0}, testCar{100, 1})
+		m := &ctfMode{scoreLimit: 3}
+		m.Start(round)
+		test.setup(round, &m.flags[1])
+		m.play(round, 1)
+
+		flag := m.flags[1]
+		if flag.Carrier != test.carrier || flag.Position != test.position || m.captures[0] != test.captures {
+			t.Errorf("%s: carrier %d at %v with %d captures, want %d at %v with %d", test.name,
+				flag.Carrier, flag.Position, m.captures[0], test.carrier, test.position, test.captures)
+		}
+	}
+}
+
+func TestCtfWinner(t *testing.T) {
+	tests := []struct {
+		name     string
+		captures []int
+		round    *Round
+		winner   string
+	}{
+		{"no captures", []int{0, 0}, testRound(2, testCar{100, 0}, testCar{100, 1}), ""},
+		{"below the limit", []int{2, 1}, testRound(2, testCar{100, 0}, testCar{100, 1}), ""},
+		{"score limit", []int{1, 3}, testRound(2, testCar{100, 0}, testCar{100, 1}), "THE BLUE TEAM"},
+		{"last team", []int{2, 0}, testRound(2, testCar{0, 0}, testCar{100, 1}), "THE BLUE TEAM"},
+		{"limit of the third team", []int{0, 0, 4}, testRound(3, testCar{100, 0}, testCar{100, 1}, testCar{100, 2}), "THE GREEN TEAM"},
+	}
+	for _, test := range tests {
+		m := &ctfMode{scoreLimit: 3, captures: test.captures}
+		if winner := m.Winner(test.round); winner != test.winner {
+			t.Errorf("%s: winner %q, want %q", test.name, winner, test.winner)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	conf.Log = slog.New(slog.NewTextHandler(io.Discard, nil))
+	os.Exit(m.Run())
+}
+
+// Cars of the test rounds are given as health and team pairs
+type testCar struct {
+	health int64
+	team   int
+}
+
+// Running round of the cars named A, B, C and so on, all of them in the corner of the arena
+func testRound(teams int, cars ...testCar) *Round {
+	round := &Round{Teams: teams, State: RUNNING}
+	for num, car := range cars {
+		round.Players = append(round.Players, Player{Name: string(rune('A' + num)), Health: car.health, Team: car.team})
+	}
+	return round
+}
+
+// Puts the car on the point, the left upper corner of the car is there
+func driveTo(round *Round, num int, p Point) {
+	round.Players[num].Car.Borders = newRectangle(p.X, p.Y, horizontalCarWidth, horizontalCarHeight)
+}
+
+// Moves the clock of the game mechanics for the test
+func setNow(t *testing.T, at time.Time) {
+	old := now
+	now = func() time.Time { return at }
+	t.Cleanup(func() { now = old })
+}
+
+// Time of the game mechanics in the tests
+var testStart = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
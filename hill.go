@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+King of the hill: a zone in the middle of the arena scores a point every
+second for the car holding it alone, or for its team. The hill is contested
+and scores nothing while cars of several teams are inside. The first to
+the score limit or the last team standing wins
+*/
+
+const hillGlyph = "\xE2\x96\x92"
+
+const hillWidth = 21
+const hillHeight = 7
+
+type hillMode struct {
+	scoreLimit int
+	hill       Rectangle
+	// Points of every team, in free-for-all every car is a team
+	points   map[int]int
+	holder   int
+	lastTick time.Time
+}
+
+func (m *hillMode) Start(round *Round) {
+	arena := arenaRectangle()
+	center := arena.center()
+	m.hill = newRectangle(center.X-hillWidth/2, center.Y-hillHeight/2, hillWidth, hillHeight)
+	m.points = make(map[int]int)
+	m.holder = -1
+}
+
+func (m *hillMode) Apply(round *Round, activeFrameBuffer []Symbol) {
+	if round.State == RUNNING {
+		m.play(round)
+	}
+
+	color := DIM
+	if m.holder >= 0 {
+		color = m.holderColor(round)
+	} else if m.contested(round) {
+		color = BOLD
+	}
+	drawWhereEmpty(activeFrameBuffer, m.hill.edge(), Symbol{color, []byte(hillGlyph)})
+
+	for team := 0; team < round.Teams; team++ {
+		round.applyTeamScore(activeFrameBuffer, team, formatHillScore(m.points[team], m.scoreLimit))
+	}
+	if m.contested(round) {
+		round.applyModeStatus(activeFrameBuffer, "Hill contested!", BOLD)
+	}
+}
+
+// Scores a point every second for the team alone on the hill
+func (m *hillMode) play(round *Round) {
+	m.holder = -1
+	teams := m.teamsOnHill(round)
+	if len(teams) == 1 {
+		for team := range teams {
+			m.holder = team
+		}
+	}
+
+	if now().Sub(m.lastTick) < time.Second {
+		return
+	}
+	m.lastTick = now()
+	if m.holder >= 0 {
+		m.points[m.holder]++
+	}
+}
+
+func (m *hillMode) teamsOnHill(round *Round) map[int]bool {
+	teams := make(map[int]bool)
+	for num := range round.Players {
+		p := &round.Players[num]
+		if p.Health > 0 && !p.respawning() && p.Car.Borders.intersects(&m.hill) {
+			teams[p.Team] = true
+		}
+	}
+	return teams
+}
+
+func (m *hillMode) contested(round *Round) bool {
+	return round.State == RUNNING && len(m.teamsOnHill(round)) > 1
+}
+
+func (m *hillMode) holderColor(round *Round) int {
+	if round.Teams > 0 {
+		return teamColors[m.holder]
+	}
+	return round.Players[m.holder].Color
+}
+
+func (m *hillMode) Score(round *Round, num int) string {
+	return formatHillScore(m.points[round.Players[num].Team], m.scoreLimit)
+}
+
+func formatHillScore(points, limit int) string {
+	return fmt.Sprintf("Hill: %d/%d", points, limit)
+}
+
+func (m *hillMode) Winner(round *Round) string {
+	for num, p := range round.Players {
+		if m.points[p.Team] >= m.scoreLimit {
+			return round.sideName(num)
+		}
+	}
+	return round.lastTeamStanding()
+}
+
+// Bots away from the hill drive to its middle
+func (m *hillMode) Goal(round *Round, num int) (Point, bool) {
+	if round.Players[num].Car.Borders.intersects(&m.hill) {
+		return Point{}, false
+	}
+	return m.hill.center(), true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHillScoring(t *testing.T) {
+	tests := []struct {
+		name      string
+		round     *Round
+		onHill    []int
+		ticks     []time.Duration
+		holder    int
+		points    int
+		contested bool
+	}{
+		{"empty", testRound(0, testCar{100, 0}, testCar{100, 1}), nil, []time.Duration{0}, -1, 0, false},
+		{"alone", testRound(0, testCar{100, 0}, testCar{100, 1}), []int{0}, []time.Duration{0}, 0, 1, false},
+		{"every second", testRound(0, testCar{100, 0}, testCar{100, 1}), []int{0}, []time.Duration{0, 500 * time.Millisecond, time.Second}, 0, 2, false},
+		{"contested", testRound(0, testCar{100, 0}, testCar{100, 1}), []int{0, 1}, []time.Duration{0}, -1, 0, true},
+		{"teammates", testRound(2, testCar{100, 0}, testCar{100, 0}, testCar{100, 1}), []int{0, 1}, []time.Duration{0}, 0, 1, false},
+		{"wreck", testRound(0, testCar{100, 0}, testCar{0, 1}), []int{0, 1}, []time.Duration{0}, 0, 1, false},
+	}
+	for _, test := range tests {
+		m := &hillMode{scoreLimit: 10}
+		m.Start(test.round)
+		for _, num := range test.onHill {
+			driveTo(test.round, num, m.hill.Points[LEFTUP])
+		}
+		for _, tick := range test.ticks {
+			setNow(t, testStart.Add(tick))
+			m.play(test.round)
+		}
+
+		if m.holder != test.holder || m.points[0] != test.points || m.contested(test.round) != test.contested {
+			t.Errorf("%s: holder %d with %d points, contested %t, want %d with %d, %t", test.name,
+				m.holder, m.points[0], m.contested(test.round), test.holder, test.points, test.contested)
+		}
+	}
+}
+
+// Cars waiting for the respawn are off the arena
+func TestHillRespawning(t *testing.T) {
+	setNow(t, testStart)
+	round := testRound(0, testCar{100, 0}, testCar{100, 1})
+	m := &hillMode{scoreLimit: 10}
+	m.Start(round)
+	driveTo(round, 0, m.hill.Points[LEFTUP])
+	driveTo(round, 1, m.hill.Points[LEFTUP])
+	round.Players[1].RespawnAt = testStart.Add(time.Second)
+	m.play(round)
+	if m.holder != 0 || m.points[0] != 1 {
+		t.Errorf("Holder %d with %d points, want the car on the hill", m.holder, m.points[0])
+	}
+}
+
+func TestHillWinner(t *testing.T) {
+	tests := []struct {
+		name   string
+		round  *Round
+		points map[int]int
+		winner string
+	}{
+		{"no points", testRound(0, testCar{100, 0}, testCar{100, 1}), map[int]int{}, ""},
+		{"below the limit", testRound(0, testCar{100, 0}, testCar{100, 1}), map[int]int{0: 9, 1: 5}, ""},
+		{"score limit", testRound(0, testCar{100, 0}, testCar{100, 1}), map[int]int{0: 4, 1: 10}, "B"},
+		{"last car", testRound(0, testCar{100, 0}, testCar{0, 1}), map[int]int{1: 9}, "A"},
+		{"team limit", testRound(2, testCar{100, 0}, testCar{100, 1}, testCar{100, 1}), map[int]int{1: 10}, "THE BLUE TEAM"},
+		{"last team", testRound(2, testCar{0, 0}, testCar{100, 1}), map[int]int{0: 9}, "THE BLUE TEAM"},
+	}
+	for _, test := range tests {
+		m := &hillMode{scoreLimit: 10, points: test.points}
+		if winner := m.Winner(test.round); winner != test.winner {
+			t.Errorf("%s: winner %q, want %q", test.name, winner, test.winner)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Amount of tracked addresses after which idle rate limiters are forgotten
+const maxRateBuckets = 10000
+const rejectWriteTimeout = time.Second
+
+// Reasons of rejects
+const (
+	rejectServerFull = "server_full"
+	rejectTooManyIP  = "too_many_per_ip"
+	rejectRate       = "rate"
+)
+
+var rejectMessages = map[string]string{
+	rejectServerFull: "Sorry, the server is full. Please try again later.",
+	rejectTooManyIP:  "Sorry, there are too many connections from your address.",
+	rejectRate:       "Sorry, you are connecting too often. Please wait a minute.",
+}
+
+// Token bucket refilled by rate tokens per second
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type connLimiter struct {
+	sync.Mutex
+	maxTotal int
+	maxPerIP int
+	rate     float64
+	burst    float64
+	total    int
+	perIP    map[string]int
+	buckets  map[string]*rateBucket
+}
+
+/*
+Zero values disable the corresponding limit. Rate is the amount of connections
+per minute from one address, burst is how many of them may come at once
+*/
+func newConnLimiter(maxTotal, maxPerIP int, perMinute float64, burst int) *connLimiter {
+	return &connLimiter{
+		maxTotal: maxTotal,
+		maxPerIP: maxPerIP,
+		rate:     perMinute / 60,
+		burst:    float64(burst),
+		perIP:    make(map[string]int),
+		buckets:  make(map[string]*rateBucket),
+	}
+}
+
+// Returns the reason of the reject or empty string if the connection is allowed
+func (l *connLimiter) acquire(ip string) string {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.rate > 0 && !l.takeToken(ip) {
+		return rejectRate
+	}
+	if l.maxTotal > 0 && l.total >= l.maxTotal {
+		return rejectServerFull
+	}
+	if l.maxPerIP > 0 && l.perIP[ip] >= l.maxPerIP {
+		return rejectTooManyIP
+	}
+	l.total++
+	l.perIP[ip]++
+	return ""
+}
+
+func (l *connLimiter) release(ip string) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.total--
+	l.perIP[ip]--
+	if l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+}
+
+func (l *connLimiter) takeToken(ip string) bool {
+	now := time.Now()
+	if len(l.buckets) > maxRateBuckets {
+		for k, b := range l.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+				delete(l.buckets, k)
+			}
+		}
+	}
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &rateBucket{tokens: l.burst, last: now}
+		l.buckets[ip] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// limitedConn gives its slot back to the limiter once it is closed
+type limitedConn struct {
+	net.Conn
+	limiter *connLimiter
+	ip      string
+	once    sync.Once
+}
+
+func (c *limitedConn) Close() error {
+	c.once.Do(func() {
+		c.limiter.release(c.ip)
+	})
+	return c.Conn.Close()
+}
+
+// All clients of Unix sockets share the same limits
+func ipOf(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(addrOf(conn))
+	if err != nil {
+		return addrOf(conn)
+	}
+	return host
+}
+
+func (l *connLimiter) limit(conn net.Conn) (net.Conn, string) {
+	ip := ipOf(conn)
+	if reason := l.acquire(ip); reason != "" {
+		return nil, reason
+	}
+	return &limitedConn{Conn: conn, limiter: l, ip: ip}, ""
+}
+
+// Explains the client why it is going to be disconnected
+func reject(conn net.Conn, message string) {
+	conn.SetWriteDeadline(time.Now().Add(rejectWriteTimeout))
+	conn.Write(clear)
+	conn.Write(home)
+	conn.Write([]byte(message + "\r\n"))
+}
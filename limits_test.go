@@ -0,0 +1,76 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestConnLimits(t *testing.T) {
+	// Steps are "+ip" to connect and "-ip" to disconnect, rejects are of the connections
+	tests := []struct {
+		name     string
+		maxTotal int
+		maxPerIP int
+		steps    []string
+		rejects  []string
+	}{
+		{"no limits", 0, 0, []string{"+a", "+a", "+b"}, []string{"", "", ""}},
+		{"total", 2, 0, []string{"+a", "+b", "+c"}, []string{"", "", rejectServerFull}},
+		{"total released", 2, 0, []string{"+a", "+b", "-a", "+c"}, []string{"", "", ""}},
+		{"per IP", 0, 2, []string{"+a", "+a", "+a", "+b"}, []string{"", "", rejectTooManyIP, ""}},
+		{"per IP released", 0, 2, []string{"+a", "+a", "-a", "+a"}, []string{"", "", ""}},
+		{"full before per IP", 2, 1, []string{"+a", "+b", "+a"}, []string{"", "", rejectServerFull}},
+		{"rejects take no slots", 3, 1, []string{"+a", "+a", "+a", "+b", "+c"}, []string{"", rejectTooManyIP, rejectTooManyIP, "", ""}},
+	}
+	for _, test := range tests {
+		l := newConnLimiter(test.maxTotal, test.maxPerIP, 0, 0)
+		var rejects []string
+		for _, step := range test.steps {
+			if step[0] == '-' {
+				l.release(step[1:])
+				continue
+			}
+			rejects = append(rejects, l.acquire(step[1:]))
+		}
+		if !slices.Equal(rejects, test.rejects) {
+			t.Errorf("%s: rejects %q, want %q", test.name, rejects, test.rejects)
+		}
+	}
+}
+
+// Token buckets refill with the rate per minute up to the burst
+func TestRateBucket(t *testing.T) {
+	tests := []struct {
+		name    string
+		after   time.Duration
+		allowed int
+	}{
+		{"empty", 0, 0},
+		{"part of a token", 500 * time.Millisecond, 0},
+		{"one token", time.Second, 1},
+		{"two tokens", 2 * time.Second, 2},
+		{"up to the burst", time.Minute, 3},
+	}
+	for _, test := range tests {
+		l := newConnLimiter(0, 0, 60, 3)
+		for range 3 {
+			if reason := l.acquire("a"); reason != "" {
+				t.Fatalf("%s: burst is rejected with %s", test.name, reason)
+			}
+		}
+		// The time of the last take moves back instead of the clock moving forward
+		l.buckets["a"].last = l.buckets["a"].last.Add(-test.after)
+
+		allowed := 0
+		for l.acquire("a") == "" {
+			allowed++
+		}
+		if allowed != test.allowed {
+			t.Errorf("%s: %d connections are allowed, want %d", test.name, allowed, test.allowed)
+		}
+		if l.acquire("b") != "" {
+			t.Errorf("%s: other address is rate limited", test.name)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+const unixPrefix = "unix:"
+
+/*
+Listens an address in a form of host:port, [ipv6]:port, :port or unix:/path/to/socket.
+Private sockets are created accessible only for the owner of the process,
+there is no moment when others can connect
+*/
+func listen(address string, private bool) (net.Listener, error) {
+	path, ok := strings.CutPrefix(address, unixPrefix)
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+
+	// Remove the socket left after the previous run, but never other files
+	info, err := os.Lstat(path)
+	if err == nil && info.Mode()&os.ModeSocket == 0 {
+		return nil, fmt.Errorf("%s exists and is not a socket", path)
+	} else if err == nil {
+		if err := removeStaleSocket(path); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if private {
+		defer syscall.Umask(syscall.Umask(0077))
+	}
+	return net.Listen("unix", path)
+}
+
+// The socket is stale if nobody accepts connections on it, like after a crash
+func removeStaleSocket(path string) error {
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is used by another process", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return err
+	}
+	return os.Remove(path)
+}
+
+/*
+Listens the comma separated list of addresses. Empty list means nothing to listen
+*/
+func listenAll(addresses string, private bool) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, address := range strings.Split(addresses, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		l, err := listen(address, private)
+		if err != nil {
+			closeAll(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+func closeAll(listeners []net.Listener) {
+	for _, l := range listeners {
+		l.Close()
+	}
+}
+
+func isLocal(l net.Listener) bool {
+	switch addr := l.Addr().(type) {
+	case *net.UnixAddr:
+		return true
+	case *net.TCPAddr:
+		return addr.IP.IsLoopback()
+	}
+	return false
+}
+
+// Clients of Unix sockets don't have an address
+func addrOf(conn net.Conn) string {
+	if conn.RemoteAddr() == nil || conn.RemoteAddr().String() == "" {
+		return conn.LocalAddr().String()
+	}
+	return conn.RemoteAddr().String()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crashci.sock")
+	l, err := listen(unixPrefix+path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm()&0077 != 0 {
+		t.Errorf("Others can use the private socket with mode %v, %v", info.Mode().Perm(), err)
+	}
+
+	// The socket of the running server is kept
+	if second, err := listen(unixPrefix+path, true); err == nil {
+		second.Close()
+		t.Error("Socket in use is taken")
+	}
+
+	// The socket left after the crash is replaced
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	l, err = listen(unixPrefix+path, false)
+	if err != nil {
+		t.Fatalf("Stale socket is not replaced: %v", err)
+	}
+	l.Close()
+}
+
+func TestListenNotSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crashci.sock")
+	if err := os.WriteFile(path, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := listen(unixPrefix+path, false); err == nil {
+		l.Close()
+		t.Error("File is replaced with the socket")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "data" {
+		t.Errorf("File is changed: %q, %v", data, err)
+	}
+}
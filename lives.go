@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+Lives: an eliminated car with lives left disappears for the respawn delay
+and comes back at the seat farthest from other cars. It can't be hurt for a
+while after that. The car or the bomb which hit the car last gets the
+elimination, the round is won by the most eliminations when the time is out
+or by the last team with lives
+*/
+
+// Damage older than this doesn't count as the elimination, like a lonely crash into the wall
+const eliminationCreditSec = 5
+
+type livesMode struct {
+	lives           int
+	respawnDelay    time.Duration
+	invulnerability time.Duration
+	duration        time.Duration
+
+	left         []int
+	eliminations []int
+	started      time.Time
+}
+
+// Modes which may bring eliminated cars back
+type respawnMode interface {
+	Eliminated(round *Round, player *Player)
+}
+
+func (m *livesMode) Start(round *Round) {
+	m.left = make([]int, len(round.Players))
+	m.eliminations = make([]int, len(round.Players))
+	for num := range m.left {
+		m.left[num] = m.lives
+	}
+}
+
+func (m *livesMode) Eliminated(round *Round, player *Player) {
+	num := round.indexOf(player)
+	if num < 0 {
+		return
+	}
+
+	by := player.LastHitBy
+	if by != num && now().Unix()-player.LastHitAt <= eliminationCreditSec &&
+		(round.Teams == 0 || round.Players[by].Team != player.Team) {
+		m.eliminations[by]++
+		player.logger(round).Info("Player was eliminated", "by", round.Players[by].Name)
+	}
+
+	m.left[num]--
+	if m.left[num] <= 0 {
+		return
+	}
+	player.Health = 100
+	player.LastHitAt = 0
+	player.Car.Speed = 1
+	player.RespawnAt = now().Add(m.respawnDelay)
+}
+
+func (m *livesMode) Apply(round *Round, activeFrameBuffer []Symbol) {
+	if round.State == RUNNING && m.started.IsZero() {
+		m.started = now()
+	}
+
+	for num := range round.Players {
+		p := &round.Players[num]
+		if !p.RespawnAt.IsZero() && now().After(p.RespawnAt) {
+			m.respawn(round, num)
+		}
+		round.applySidebarLine(activeFrameBuffer, num, 4, fmt.Sprintf("Lives: %d Kills: %d", max(m.left[num], 0), m.eliminations[num]), p.Color)
+	}
+
+	for team := 0; team < round.Teams; team++ {
+		round.applyTeamScore(activeFrameBuffer, team, fmt.Sprintf("%d eliminations", m.teamEliminations(round, team)))
+	}
+	if !m.started.IsZero() {
+		left := m.duration - now().Sub(m.started)
+		round.applyModeStatus(activeFrameBuffer, "Time left: "+formatRaceTime(max(left, 0)), BOLD)
+	}
+}
+
+func (m *livesMode) respawn(round *Round, num int) {
+	p := &round.Players[num]
+	seat := m.safeSeat(round, num)
+	p.Car.Borders, p.Car.Direction = seat.Borders, seat.Direction
+	// limitHealth paints the wreck gray if it caught the car at 0
+	p.initTeam(num, round.Teams)
+	p.InvulnerableUntil = now().Add(m.invulnerability)
+	p.RespawnAt = time.Time{}
+}
+
+// The seat with the most space to the closest car
+func (m *livesMode) safeSeat(round *Round, num int) Car {
+	var best Car
+	bestDistance := -1
+	for seat := 0; seat < maxPlayersPerRound; seat++ {
+		car := seatCar(seat)
+		center := car.Borders.center()
+		closest := mapWidth + mapHeight
+		for i := range round.Players {
+			other := &round.Players[i]
+			if i == num || other.Health <= 0 || other.respawning() {
+				continue
+			}
+			c := other.Car.Borders.center()
+			closest = min(closest, abs(c.X-center.X)+verticalCost*abs(c.Y-center.Y))
+		}
+		if closest > bestDistance {
+			best, bestDistance = car, closest
+		}
+	}
+	return best
+}
+
+func (m *livesMode) teamEliminations(round *Round, team int) int {
+	eliminations := 0
+	for num, p := range round.Players {
+		if p.Team == team {
+			eliminations += m.eliminations[num]
+		}
+	}
+	return eliminations
+}
+
+func (m *livesMode) Winner(round *Round) string {
+	if winner := round.lastTeamStanding(); winner != "" {
+		return winner
+	}
+	if m.started.IsZero() || now().Sub(m.started) < m.duration {
+		return ""
+	}
+
+	// Ties are won together
+	var winners []string
+	best := -1
+	for num := range round.Players {
+		eliminations := m.eliminations[num]
+		if round.Teams > 0 {
+			if num >= round.Teams {
+				break
+			}
+			eliminations = m.teamEliminations(round, num)
+		}
+		if eliminations > best {
+			winners, best = nil, eliminations
+		}
+		if eliminations == best {
+			if round.Teams > 0 {
+				winners = append(winners, teamTitle(num))
+			} else {
+				winners = append(winners, round.sideName(num))
+			}
+		}
+	}
+	return strings.Join(winners, " AND ")
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
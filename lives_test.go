@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLivesEliminated(t *testing.T) {
+	tests := []struct {
+		name         string
+		lives        int
+		round        *Round
+		by           int
+		ago          time.Duration
+		eliminations int
+		respawn      bool
+	}{
+		{"hit by the other car", 2, testRound(0, testCar{0, 0}, testCar{100, 1}), 1, 2 * time.Second, 1, true},
+		{"crashed long after the hit", 2, testRound(0, testCar{0, 0}, testCar{100, 1}), 1, 10 * time.Second, 0, true},
+		{"own bomb", 2, testRound(0, testCar{0, 0}, testCar{100, 1}), 0, time.Second, 0, true},
+		{"teammate", 2, testRound(2, testCar{0, 0}, testCar{100, 0}, testCar{100, 1}), 1, time.Second, 0, true},
+		{"last life", 1, testRound(0, testCar{0, 0}, testCar{100, 1}), 1, time.Second, 1, false},
+	}
+	for _, test := range tests {
+		setNow(t, testStart)
+		m := &livesMode{lives: test.lives, respawnDelay: 3 * time.Second}
+		m.Start(test.round)
+		victim := &test.round.Players[0]
+		victim.LastHitBy, victim.LastHitAt = test.by, testStart.Add(-test.ago).Unix()
+		m.Eliminated(test.round, victim)
+
+		if m.eliminations[test.by] != test.eliminations {
+			t.Errorf("%s: %d eliminations, want %d", test.name, m.eliminations[test.by], test.eliminations)
+		}
+		if m.left[0] != test.lives-1 {
+			t.Errorf("%s: %d lives left, want %d", test.name, m.left[0], test.lives-1)
+		}
+		if victim.respawning() != test.respawn || (victim.Health > 0) != test.respawn {
+			t.Errorf("%s: respawning %t with health %d, want %t", test.name, victim.respawning(), victim.Health, test.respawn)
+		}
+	}
+}
+
+// The car comes back at the free seat and can't be hurt for a while
+func TestLivesRespawn(t *testing.T) {
+	setNow(t, testStart)
+	round := testRound(0, testCar{0, 0}, testCar{100, 1}, testCar{100, 2}, testCar{100, 3}, testCar{100, 4})
+	for num := 1; num < len(round.Players); num++ {
+		round.Players[num].Car = seatCar(num - 1)
+	}
+	m := &livesMode{lives: 3, respawnDelay: 3 * time.Second, invulnerability: 2 * time.Second}
+	m.Start(round)
+	p := &round.Players[0]
+	m.Eliminated(round, p)
+	if !p.RespawnAt.Equal(testStart.Add(3 * time.Second)) {
+		t.Errorf("Respawn at %s, want in 3s", p.RespawnAt.Sub(testStart))
+	}
+
+	setNow(t, p.RespawnAt)
+	m.respawn(round, 0)
+	if p.respawning() || p.Car.Borders != seatCar(4).Borders {
+		t.Errorf("Respawned at %v, want the free seat %v", p.Car.Borders, seatCar(4).Borders)
+	}
+	for _, test := range []struct {
+		after        time.Duration
+		invulnerable bool
+	}{
+		{0, true},
+		{time.Second, true},
+		{2 * time.Second, false},
+	} {
+		setNow(t, testStart.Add(3*time.Second+test.after))
+		if p.invulnerable() != test.invulnerable {
+			t.Errorf("Invulnerable %t %s after the respawn", p.invulnerable(), test.after)
+		}
+	}
+}
+
+func TestLivesWinner(t *testing.T) {
+	tests := []struct {
+		name         string
+		round        *Round
+		eliminations []int
+		elapsed      time.Duration
+		winner       string
+	}{
+		{"time is running", testRound(0, testCar{100, 0}, testCar{100, 1}), []int{3, 1}, time.Minute, ""},
+		{"most eliminations", testRound(0, testCar{100, 0}, testCar{100, 1}), []int{3, 1}, 3 * time.Minute, "A"},
+		{"tie", testRound(0, testCar{100, 0}, testCar{100, 1}, testCar{100, 2}), []int{2, 2, 1}, 3 * time.Minute, "A AND B"},
+		{"last car with lives", testRound(0, testCar{0, 0}, testCar{100, 1}), []int{3, 0}, time.Minute, "B"},
+		{"team eliminations", testRound(2, testCar{100, 0}, testCar{100, 0}, testCar{100, 1}), []int{1, 1, 3}, 3 * time.Minute, "THE BLUE TEAM"},
+		{"team tie", testRound(2, testCar{100, 0}, testCar{100, 1}, testCar{100, 1}), []int{2, 1, 1}, 3 * time.Minute, "THE RED TEAM AND THE BLUE TEAM"},
+		{"last team with lives", testRound(2, testCar{0, 0}, testCar{0, 0}, testCar{100, 1}), []int{5, 5, 0}, time.Minute, "THE BLUE TEAM"},
+	}
+	for _, test := range tests {
+		m := &livesMode{duration: 2 * time.Minute, started: testStart, eliminations: test.eliminations}
+		setNow(t, testStart.Add(test.elapsed))
+		if winner := m.Winner(test.round); winner != test.winner {
+			t.Errorf("%s: winner %q, want %q", test.name, winner, test.winner)
+		}
+	}
+}
+
+// A round which hasn't started yet is not over, however long the lobby took
+func TestLivesWinnerBeforeStart(t *testing.T) {
+	m := &livesMode{duration: time.Minute, eliminations: []int{0, 0}}
+	if winner := m.Winner(testRound(0, testCar{100, 0}, testCar{100, 1})); winner != "" {
+		t.Errorf("Winner %q before the start", winner)
+	}
+}
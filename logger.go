@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+)
+
+// Lifecycle events
+const (
+	eventConnect      = "connect"
+	eventNameAccepted = "name_accepted"
+	eventNameRejected = "name_rejected"
+	eventJoin         = "join"
+	eventStateChange  = "state_change"
+	eventDeath        = "death"
+	eventDisconnect   = "disconnect"
+)
+
+func newLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "logfmt":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
+
+func (round *Round) logger() *slog.Logger {
+	return conf.Log.With("round", round.Id)
+}
+
+func (player *Player) logger(round *Round) *slog.Logger {
+	l := round.logger().With("player", player.Name, "bot", player.Bot)
+	if player.Conn != nil {
+		l = l.With("addr", addrOf(player.Conn))
+	}
+	return l
+}
+
+/*
+Attaches context to the connection, so the disconnect is logged with
+the player and the round it belonged to
+*/
+func annotateConn(conn net.Conn, args ...any) {
+	if c, ok := conn.(*meteredConn); ok {
+		c.log = c.log.With(args...)
+	}
+}
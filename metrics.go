@@ -0,0 +1,141 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	connectedClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "crashci_connected_clients",
+		Help: "Number of currently connected telnet clients.",
+	})
+	roundsByState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crashci_rounds",
+		Help: "Number of rounds by state.",
+	}, []string{"state"})
+	roundPlayers = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "crashci_round_players",
+		Help:    "Number of human players in a round when it starts.",
+		Buckets: prometheus.LinearBuckets(1, 1, maxPlayersPerRound),
+	})
+	frameRenderDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "crashci_frame_render_seconds",
+		Help:    "Time spent rendering one frame of a round.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 12),
+	})
+	sentBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "crashci_sent_bytes_total",
+		Help: "Total number of bytes sent to clients.",
+	})
+	clientSentBytes = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "crashci_client_sent_bytes",
+		Help:    "Number of bytes sent to a client during its connection.",
+		Buckets: prometheus.ExponentialBuckets(1024, 4, 10),
+	})
+	botsCount = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "crashci_bots",
+		Help: "Number of bots in running rounds.",
+	})
+	roundDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "crashci_round_duration_seconds",
+		Help:    "Duration of rounds from start to finish.",
+		Buckets: prometheus.LinearBuckets(30, 30, maxRoundRunningTimeSec/30),
+	})
+	disconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crashci_disconnects_total",
+		Help: "Number of client disconnects by reason.",
+	}, []string{"reason"})
+	rejects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crashci_rejected_connections_total",
+		Help: "Number of rejected connections by reason.",
+	}, []string{"reason"})
+)
+
+// Reasons of disconnects
+const (
+	reasonClosed     = "closed"
+	reasonBadName    = "bad_name"
+	reasonQuit       = "quit"
+	reasonReadError  = "read_error"
+	reasonWriteError = "write_error"
+	reasonRoundOver  = "round_over"
+	reasonKicked     = "kicked"
+	reasonTimeout    = "timeout"
+	reasonBanned     = "banned"
+	reasonAfk        = "afk"
+	reasonBadToken   = "bad_token"
+)
+
+func init() {
+	prometheus.MustRegister(
+		connectedClients,
+		roundsByState,
+		roundPlayers,
+		frameRenderDuration,
+		sentBytes,
+		clientSentBytes,
+		botsCount,
+		roundDuration,
+		disconnects,
+		rejects,
+	)
+}
+
+/*
+meteredConn counts bytes written to the client and records
+the reason of the disconnect only once
+*/
+type meteredConn struct {
+	net.Conn
+	sent int64
+	once sync.Once
+	log  *slog.Logger
+}
+
+func newMeteredConn(conn net.Conn) *meteredConn {
+	connectedClients.Inc()
+	atomic.AddInt64(&activeClients, 1)
+	return &meteredConn{Conn: conn, log: conf.Log.With("addr", addrOf(conn))}
+}
+
+func (c *meteredConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.sent, int64(n))
+	sentBytes.Add(float64(n))
+	return n, err
+}
+
+func (c *meteredConn) Close() error {
+	return c.disconnect(reasonClosed)
+}
+
+func (c *meteredConn) disconnect(reason string) error {
+	var err error
+	c.once.Do(func() {
+		err = c.Conn.Close()
+		connectedClients.Dec()
+		atomic.AddInt64(&activeClients, -1)
+		disconnects.WithLabelValues(reason).Inc()
+		clientSentBytes.Observe(float64(atomic.LoadInt64(&c.sent)))
+		c.log.Info("Client disconnected", "event", eventDisconnect, "reason", reason, "sent", atomic.LoadInt64(&c.sent))
+	})
+	return err
+}
+
+func disconnect(conn net.Conn, reason string) {
+	if c, ok := conn.(*meteredConn); ok {
+		c.disconnect(reason)
+		return
+	}
+	conn.Close()
+}
+
+func observeFrame(start time.Time) {
+	frameRenderDuration.Observe(time.Since(start).Seconds())
+}
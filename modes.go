@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+GameMode adds the rules of the round on top of driving and crashing.
+Every round gets its own mode, so modes may keep state of the round
+*/
+type GameMode interface {
+	// Called once before the round starts
+	Start(round *Round)
+	// Plays the rules and draws them over the cars, called every frame
+	Apply(round *Round, activeFrameBuffer []Symbol)
+	// Name of the winner, empty while the round goes on
+	Winner(round *Round) string
+}
+
+// Modes which give cars somewhere to go instead of hunting others
+type goalMode interface {
+	Goal(round *Round, num int) (Point, bool)
+}
+
+// Modes which show a score of every car next to its health
+type scoreMode interface {
+	Score(round *Round, num int) string
+}
+
+// Modes which fence the cars into a part of the arena, the zone is like the border of the map
+type zoneMode interface {
+	Zone(round *Round) (Rectangle, bool)
+}
+
+// Zone of the mode if the car is inside of it, cars left outside may drive in
+func (round *Round) fence(borders *Rectangle) (Rectangle, bool) {
+	if mode, ok := round.Mode.(zoneMode); ok {
+		if zone, ok := mode.Zone(round); ok && zone.containsRectangle(borders) {
+			return zone, true
+		}
+	}
+	return Rectangle{}, false
+}
+
+var gameModes = map[string]func() GameMode{
+	"deathmatch": func() GameMode { return &deathmatchMode{} },
+	"ctf":        func() GameMode { return &ctfMode{scoreLimit: conf.CtfScore} },
+	"race": func() GameMode {
+		return &raceMode{track: raceTracks[conf.Track], laps: conf.RaceLaps, bombs: conf.RaceBombs}
+	},
+	"timetrial": func() GameMode {
+		return &timeTrialMode{raceMode: raceMode{track: raceTracks[conf.Track], laps: conf.RaceLaps, bombs: conf.RaceBombs}, records: conf.LapRecords}
+	},
+	"shrink": func() GameMode {
+		return &shrinkMode{after: conf.ShrinkAfter, interval: conf.ShrinkInterval}
+	},
+	"hill": func() GameMode {
+		return &hillMode{scoreLimit: conf.HillScore}
+	},
+	"lives": func() GameMode {
+		return &livesMode{lives: conf.Lives, respawnDelay: conf.RespawnDelay, invulnerability: conf.Invulnerable, duration: conf.LivesTime}
+	},
+}
+
+// Modes which make no sense without teams
+var teamModes = map[string]bool{
+	"ctf": true,
+}
+
+// Modes played on race tracks
+var trackModes = map[string]bool{
+	"race":      true,
+	"timetrial": true,
+}
+
+// Modes played by a single car without bots
+var soloModes = map[string]bool{
+	"timetrial": true,
+}
+
+const defaultGameMode = "deathmatch"
+
+func gameModeNames() []string {
+	var names []string
+	for name := range gameModes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func validateGameMode(mode string, teams int, track string) error {
+	if _, ok := gameModes[mode]; !ok {
+		return fmt.Errorf("Unknown game mode %q, known are %v", mode, gameModeNames())
+	}
+	if teamModes[mode] && teams == 0 {
+		return fmt.Errorf("Game mode %s is played by teams", mode)
+	}
+	if trackModes[mode] && raceTracks[track] == nil {
+		return fmt.Errorf("Unknown track %q, known are %v", track, trackNames())
+	}
+	return nil
+}
+
+// Status of the mode on the first line of the sidebar footer, like the time left
+func (round *Round) applyModeStatus(activeFrameBuffer []Symbol, status string, color int) {
+	row := mapHeight - 1 - round.footerRows()
+	putText(activeFrameBuffer, row*mapWidth+(mapWidth-nameTableWidth+1), truncateText(status, nameTableWidth-3), color)
+}
+
+// Zones of modes are drawn under everything else on the arena
+func drawWhereEmpty(activeFrameBuffer []Symbol, points []Point, symbol Symbol) {
+	for _, p := range points {
+		if string(activeFrameBuffer[p.Y*mapWidth+p.X].Char) == " " {
+			activeFrameBuffer[p.Y*mapWidth+p.X] = symbol
+		}
+	}
+}
+
+// deathmatchMode is won by the last team standing, every car is a team in free-for-all
+type deathmatchMode struct{}
+
+func (m *deathmatchMode) Start(round *Round) {}
+
+func (m *deathmatchMode) Apply(round *Round, activeFrameBuffer []Symbol) {
+	for team := 0; team < round.Teams; team++ {
+		alive, health := round.teamStatus(team)
+		round.applyTeamScore(activeFrameBuffer, team, fmt.Sprintf("%d alive, %d HP", alive, health))
+	}
+}
+
+func (m *deathmatchMode) Winner(round *Round) string {
+	return round.lastTeamStanding()
+}
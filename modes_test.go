@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestDeathmatchWinner(t *testing.T) {
+	tests := []struct {
+		name   string
+		round  *Round
+		winner string
+	}{
+		{"all alive", testRound(0, testCar{100, 0}, testCar{100, 1}, testCar{100, 2}), ""},
+		{"last car", testRound(0, testCar{0, 0}, testCar{30, 1}, testCar{0, 2}), "B"},
+		{"nobody alive", testRound(0, testCar{0, 0}, testCar{0, 1}), ""},
+		{"alone", testRound(0, testCar{100, 0}), ""},
+		{"two teams alive", testRound(2, testCar{100, 0}, testCar{0, 0}, testCar{100, 1}), ""},
+		{"last team", testRound(2, testCar{0, 0}, testCar{0, 0}, testCar{10, 1}, testCar{20, 1}), "THE BLUE TEAM"},
+		{"single team", testRound(2, testCar{100, 0}, testCar{100, 0}), ""},
+	}
+	for _, test := range tests {
+		if winner := (&deathmatchMode{}).Winner(test.round); winner != test.winner {
+			t.Errorf("%s: winner %q, want %q", test.name, winner, test.winner)
+		}
+	}
+}
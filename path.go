@@ -0,0 +1,204 @@
+package main
+
+import (
+	"container/heap"
+)
+
+// Vertical moves take 3x longer than horizontal ones, see checkPosition
+const verticalCost = 3
+
+/*
+Cells around bombs and cars which the path keeps away from, so the bot
+doesn't touch them with the corner on the turn
+*/
+const pathMargin = 1
+
+// Position of the car in the search is its LEFTUP corner
+type pathNode struct {
+	position Point
+	cost     int
+	priority int
+	index    int
+}
+
+type pathQueue []*pathNode
+
+func (q pathQueue) Len() int           { return len(q) }
+func (q pathQueue) Less(i, j int) bool { return q[i].priority < q[j].priority }
+func (q pathQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *pathQueue) Push(x any) {
+	node := x.(*pathNode)
+	node.index = len(*q)
+	*q = append(*q, node)
+}
+
+func (q *pathQueue) Pop() any {
+	old := *q
+	node := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return node
+}
+
+/*
+pathFinder searches the arena with A* for the car of the bot. Bombs, walls
+of tracks and other cars are obstacles, the borders of the arena limit
+the positions of the car
+*/
+type pathFinder struct {
+	arena         Rectangle
+	width, height int
+	blocked       []bool
+}
+
+func newPathFinder(s *RoundSnapshot) *pathFinder {
+	f := &pathFinder{
+		arena:   s.Arena,
+		width:   s.Arena.Points[RIGHTDOWN].X + 1,
+		height:  s.Arena.Points[RIGHTDOWN].Y + 1,
+		blocked: make([]bool, (s.Arena.Points[RIGHTDOWN].X+1)*(s.Arena.Points[RIGHTDOWN].Y+1)),
+	}
+
+	for _, bomb := range s.Bombs {
+		f.block(Rectangle{Points: [4]Point{bomb, bomb, bomb, bomb}})
+	}
+	for _, wall := range s.Walls {
+		f.block(Rectangle{Points: [4]Point{wall, wall, wall, wall}})
+	}
+	for i, car := range s.Cars {
+		if i == s.Me {
+			continue
+		}
+		// Dead cars stay on the map as wrecks
+		f.block(car.Borders)
+	}
+	return f
+}
+
+func (f *pathFinder) block(r Rectangle) {
+	for y := r.Points[LEFTUP].Y - pathMargin; y <= r.Points[RIGHTDOWN].Y+pathMargin; y++ {
+		for x := r.Points[LEFTUP].X - pathMargin; x <= r.Points[RIGHTDOWN].X+pathMargin; x++ {
+			if x >= 0 && x < f.width && y >= 0 && y < f.height {
+				f.blocked[y*f.width+x] = true
+			}
+		}
+	}
+}
+
+// Checks if the car of the size fits into the position
+func (f *pathFinder) free(position Point, w, h int) bool {
+	if position.X < f.arena.Points[LEFTUP].X || position.Y < f.arena.Points[LEFTUP].Y ||
+		position.X+w-1 > f.arena.Points[RIGHTDOWN].X || position.Y+h-1 > f.arena.Points[RIGHTDOWN].Y {
+		return false
+	}
+	for y := position.Y; y < position.Y+h; y++ {
+		for x := position.X; x < position.X+w; x++ {
+			if f.blocked[y*f.width+x] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Cost of the shortest possible way from the position to cover the goal
+func distance(position Point, w, h int, goal Point) int {
+	dx, dy := 0, 0
+	if goal.X < position.X {
+		dx = position.X - goal.X
+	} else if goal.X > position.X+w-1 {
+		dx = goal.X - (position.X + w - 1)
+	}
+	if goal.Y < position.Y {
+		dy = position.Y - goal.Y
+	} else if goal.Y > position.Y+h-1 {
+		dy = goal.Y - (position.Y + h - 1)
+	}
+	return dx + verticalCost*dy
+}
+
+func step(position Point, direction int) Point {
+	switch direction {
+	case LEFT:
+		position.X--
+	case RIGHT:
+		position.X++
+	case UP:
+		position.Y--
+	case DOWN:
+		position.Y++
+	}
+	return position
+}
+
+func opposite(direction int) int {
+	switch direction {
+	case LEFT:
+		return RIGHT
+	case RIGHT:
+		return LEFT
+	case UP:
+		return DOWN
+	}
+	return UP
+}
+
+/*
+Returns the first direction of the shortest way for the car to cover the goal
+and the cost of the way. The car can't turn around in place, so the first step
+never goes back. Direction is -1 if there is no way
+*/
+func (f *pathFinder) find(car *CarState, goal Point) (int, int) {
+	w := car.Borders.Points[RIGHTDOWN].X - car.Borders.Points[LEFTUP].X + 1
+	h := car.Borders.Points[RIGHTDOWN].Y - car.Borders.Points[LEFTUP].Y + 1
+	start := car.Borders.Points[LEFTUP]
+	if distance(start, w, h, goal) == 0 {
+		return car.Direction, 0
+	}
+
+	costs := make(map[Point]int)
+	firstSteps := make(map[Point]int)
+	queue := &pathQueue{}
+	heap.Push(queue, &pathNode{position: start, priority: distance(start, w, h, goal)})
+	costs[start] = 0
+
+	for queue.Len() > 0 {
+		node := heap.Pop(queue).(*pathNode)
+		if node.cost > costs[node.position] {
+			// Already reached cheaper
+			continue
+		}
+		if distance(node.position, w, h, goal) == 0 {
+			return firstSteps[node.position], node.cost
+		}
+
+		for direction := LEFT; direction <= DOWN; direction++ {
+			if node.position == start && direction == opposite(car.Direction) {
+				continue
+			}
+			next := step(node.position, direction)
+			if !f.free(next, w, h) {
+				continue
+			}
+			cost := node.cost + 1
+			if direction == UP || direction == DOWN {
+				cost = node.cost + verticalCost
+			}
+			if known, ok := costs[next]; ok && known <= cost {
+				continue
+			}
+			costs[next] = cost
+			if node.position == start {
+				firstSteps[next] = direction
+			} else {
+				firstSteps[next] = firstSteps[node.position]
+			}
+			heap.Push(queue, &pathNode{position: next, cost: cost, priority: cost + distance(next, w, h, goal)})
+		}
+	}
+	return -1, 0
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"math/rand"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,70 +14,96 @@ type Player struct {
 	Health    int64
 	LastCrash int64
 	Color     int
+	Team      int
 	Bot       bool
 	Bombs     int
 	DropBomb  bool
-	Car       Car
+	Muted     bool
+	LastInput int64
+	Afk       bool
+	AutoPilot bool
+	// Driven by a program over the bot protocol
+	External bool
+	// Resume token and the time the connection was lost in nanoseconds
+	Token        string
+	Disconnected int64
+	// Changes when the car changes hands, so goroutines of the previous owner stop
+	Owner int64
+	Car   Car
+	// Index of the car or the owner of the bomb which hit the car last, and the time of the hit
+	LastHitBy int
+	LastHitAt int64
+	// Set in lives rounds: the car is off the arena until RespawnAt and can't be hurt until InvulnerableUntil
+	RespawnAt         time.Time
+	InvulnerableUntil time.Time
+	// End of the effect of every kind of power-up
+	Effects [powerUpKinds]time.Time
 }
 
-func (p *Player) initPlayer(id int) {
+// Car at the start position of the seat
+func seatCar(id int) Car {
+	car := Car{Speed: 1}
 	switch id {
 	case 0:
 		initX, initY := 1, 1
-		p.Car.Borders = Rectangle{[4]Point{
+		car.Borders = Rectangle{[4]Point{
 			{initX, initY},
 			{initX + horizontalCarWidth - 1, initY},
 			{initX + horizontalCarWidth - 1, initY + horizontalCarHeight - 1},
 			{initX, initY + horizontalCarHeight - 1}}}
-		p.Car.Direction = RIGHT
+		car.Direction = RIGHT
 	case 1:
 		initX, initY := mapWidth-nameTableWidth-verticalCarWidth, 1
-		p.Car.Borders = Rectangle{[4]Point{
+		car.Borders = Rectangle{[4]Point{
 			{initX, initY},
 			{initX + verticalCarWidth - 1, initY},
 			{initX + verticalCarWidth, initY + verticalCarHeight - 1},
 			{initX, initY + verticalCarHeight - 1}}}
-		p.Car.Direction = DOWN
+		car.Direction = DOWN
 	case 2:
 		initX, initY := mapWidth-nameTableWidth-horizontalCarWidth, mapHeight-horizontalCarHeight-1
-		p.Car.Borders = Rectangle{[4]Point{
+		car.Borders = Rectangle{[4]Point{
 			{initX, initY},
 			{initX + horizontalCarWidth - 1, initY},
 			{initX + horizontalCarWidth - 1, initY + horizontalCarHeight - 1},
 			{initX, initY + horizontalCarHeight - 1}}}
-		p.Car.Direction = LEFT
+		car.Direction = LEFT
 	case 3:
 		initX, initY := 1, mapHeight-verticalCarHeight-1
-		p.Car.Borders = Rectangle{[4]Point{
+		car.Borders = Rectangle{[4]Point{
 			{initX, initY},
 			{initX + verticalCarWidth - 1, initY},
 			{initX + verticalCarWidth - 1, initY + verticalCarHeight - 1},
 			{initX, initY + verticalCarHeight - 1}}}
-		p.Car.Direction = UP
+		car.Direction = UP
 	case 4:
 		initX, initY := (mapWidth-nameTableWidth)/2, mapHeight/2
-		p.Car.Borders = Rectangle{[4]Point{
+		car.Borders = Rectangle{[4]Point{
 			{initX, initY},
 			{initX + verticalCarWidth - 1, initY},
 			{initX + verticalCarWidth - 1, initY + verticalCarHeight - 1},
 			{initX, initY + verticalCarHeight - 1}}}
-		p.Car.Direction = DOWN
+		car.Direction = DOWN
 	}
+	return car
+}
+
+func (p *Player) initPlayer(id, teams int) {
+	seat := seatCar(id)
+	p.Car.Borders, p.Car.Direction = seat.Borders, seat.Direction
 	p.Bombs = 1
-	p.LastCrash = time.Now().Add(10 * time.Second).Unix()
-	// Colors are sequential, so we can use first color RED and set the rest based on IDs
-	p.Color = RED + id
+	p.LastCrash = now().Add(10 * time.Second).Unix()
+	p.initTeam(id, teams)
 }
 
-func (p *Player) checkBestRoundForPlayer(compileRoundChannel chan Round) {
+func (p *Player) checkBestRoundForPlayer(compileRoundChannel chan *Round) {
 	foundRoundForUser := false
-	for i := 0; i < len(compileRoundChannel); i++ {
+	for i := 0; i < len(compileRoundChannel) && !foundRoundForUser; i++ {
 		select {
 		case r := <-compileRoundChannel:
 			// If any round is "compiling" now
-			if len(r.Players) < maxPlayersPerRound && !p.searchDuplicateName(&r) {
-				p.initPlayer(len(r.Players))
-				r.Players = append(r.Players, *p)
+			if len(r.Players) < r.MaxPlayers && !p.searchDuplicateName(r) {
+				p.join(r)
 				compileRoundChannel <- r
 				foundRoundForUser = true
 				break
@@ -86,15 +114,98 @@ func (p *Player) checkBestRoundForPlayer(compileRoundChannel chan Round) {
 		}
 	}
 
+	if !foundRoundForUser && conf.JoinRunning {
+		foundRoundForUser = p.takeOverBot()
+	}
+
 	if !foundRoundForUser {
 		// We need a new round
-		r := Round{Id: rand.Int(), State: COMPILING, FrameBuffer: make([]Symbol, mapWidth*mapHeight), Bonus: Point{-1, -1}, Bombs: make(map[Point]bool)}
-		p.initPlayer(len(r.Players))
-		r.Players = append(r.Players, *p)
+		r := newRound()
+		r.setState(COMPILING)
+		registry.add(r)
+		p.join(r)
 		compileRoundChannel <- r
 	}
 }
 
+func (player *Player) join(round *Round) {
+	player.initPlayer(len(round.Players), round.Teams)
+	round.Players = append(round.Players, *player)
+	player.logger(round).Info("Player joined the round", "event", eventJoin, "players", len(round.Players))
+	annotateConn(player.Conn, "round", round.Id)
+}
+
+/*
+Gives the player the car of a living bot in a running round. With JoinWindow
+only rounds started not longer than JoinWindow ago are considered
+*/
+func (p *Player) takeOverBot() bool {
+	for _, round := range registry.list() {
+		if round.State != RUNNING || p.searchDuplicateName(round) ||
+			(conf.JoinWindow > 0 && time.Since(round.LastStateChange) > conf.JoinWindow) {
+			continue
+		}
+
+		bot, replaced := p.claimBot(round)
+		if bot == nil {
+			continue
+		}
+
+		p.logger(round).Info("Player took over the bot", "event", eventJoin, "replaced", replaced)
+		bot.touch()
+		botsCount.Dec()
+		annotateConn(bot.Conn, "round", round.Id)
+
+		if !bot.External {
+			bot.Conn.Write(clear)
+		}
+		bot.play(round)
+		return true
+	}
+	return false
+}
+
+/*
+Seats the player in the car of the first living bot under the lock of the round,
+so two newcomers never get the same car. Returns the car and the name of the bot
+*/
+func (p *Player) claimBot(round *Round) (*Player, string) {
+	round.Lock()
+	defer round.Unlock()
+	for i := range round.Players {
+		bot := &round.Players[i]
+		if !bot.Bot || bot.Health <= 0 {
+			continue
+		}
+
+		replaced := bot.Name
+		bot.Conn = p.Conn
+		bot.Name = p.Name
+		bot.Token = p.Token
+		bot.External = p.External
+		// The car may belong to a human who didn't come back or went AFK
+		bot.Afk = false
+		bot.AutoPilot = false
+		bot.Muted = false
+		atomic.StoreInt64(&bot.Disconnected, 0)
+		// moveBot stops as soon as the car is not bot controlled, checkIdle and waitReconnect of the human before
+		bot.Bot = false
+		atomic.AddInt64(&bot.Owner, 1)
+		return bot, replaced
+	}
+	return nil, ""
+}
+
+/*
+Muted players are shown to others without their name
+*/
+func (player *Player) displayName(num int) string {
+	if player.Muted {
+		return fmt.Sprintf("Player %d", num+1)
+	}
+	return player.Name
+}
+
 func (player *Player) searchDuplicateName(round *Round) bool {
 	for _, pl := range round.Players {
 		if player.Name == pl.Name {
@@ -104,8 +215,28 @@ func (player *Player) searchDuplicateName(round *Round) bool {
 	return false
 }
 
+// Starts reading the input of the human or the external bot
+func (player *Player) play(round *Round) {
+	if player.External {
+		go player.readActions(round)
+		return
+	}
+	go player.readDirection(round)
+	go player.checkIdle(round)
+}
+
+// Cars can't turn around in place, trying it stops the car
+func (player *Player) turn(direction int) {
+	if player.Car.Direction != opposite(direction) {
+		player.Car.Direction = direction
+	} else if player.Car.Speed > 1 {
+		player.Car.Speed = 1
+	}
+}
+
 func (player *Player) readDirection(round *Round) {
 	if initTelnet(player.Conn) != nil {
+		player.lost(round, reasonWriteError)
 		return
 	}
 
@@ -121,9 +252,10 @@ func (player *Player) readDirection(round *Round) {
 		for {
 			_, err := player.Conn.Read(direction)
 			if err != nil {
-				player.Health = 0
+				player.lost(round, reasonReadError)
 				return
 			}
+			player.touch()
 
 			// Check if telnet want to negotiate something
 			if escpos == 0 && direction[0] == 255 {
@@ -131,6 +263,7 @@ func (player *Player) readDirection(round *Round) {
 			} else if escpos == 0 && direction[0] == 3 {
 				// Ctrl+C
 				player.Health = 0
+				disconnect(player.Conn, reasonQuit)
 				return
 			} else if escpos == 0 && direction[0] == 32 {
 				// Space
@@ -148,175 +281,83 @@ func (player *Player) readDirection(round *Round) {
 
 		switch direction[0] {
 		case 68:
-			// Left
-			if player.Car.Direction != RIGHT {
-				player.Car.Direction = LEFT
-			} else if player.Car.Speed > 1 {
-				player.Car.Speed = 1
-			}
+			player.turn(LEFT)
 		case 67:
-			// Right
-			if player.Car.Direction != LEFT {
-				player.Car.Direction = RIGHT
-			} else if player.Car.Speed > 1 {
-				player.Car.Speed = 1
-			}
+			player.turn(RIGHT)
 		case 65:
-			// Up
-			if player.Car.Direction != DOWN {
-				player.Car.Direction = UP
-			} else if player.Car.Speed > 1 {
-				player.Car.Speed = 1
-			}
+			player.turn(UP)
 		case 66:
-			// Down
-			if player.Car.Direction != UP {
-				player.Car.Direction = DOWN
-			} else if player.Car.Speed > 1 {
-				player.Car.Speed = 1
-			}
+			player.turn(DOWN)
 		}
 	}
 }
 
-/*
-Checks of the DAMAGE_SIDE is safe
-*/
-func (player *Player) checkOkSide(players []*Player, side int) bool {
-	for _, p := range players {
-		if p.Name == player.Name {
-			continue
-		}
-		// If player nextTo someone from the DAMAGE_SIDE - return false
-		if side == player.Car.Borders.nextTo(&p.Car.Borders, 3) {
-			return false
-		}
-	}
-	return true
+// Bots and humans on autopilot are driven by moveBot
+func (player *Player) botControlled() bool {
+	return player.Bot || player.AutoPilot
 }
 
-func (player *Player) navigateBot(myCenter, targetCenter *Point, restPlayers []*Player) {
-	// Chose closet way to the target
-	if targetCenter.X < myCenter.X {
-		// Target is left from us
-		if player.Car.Direction != RIGHT && player.checkOkSide(restPlayers, LEFT) {
-			player.Car.Direction = LEFT
-		} else if targetCenter.Y < myCenter.Y {
-			player.Car.Direction = UP
-		} else {
-			player.Car.Direction = DOWN
-		}
-	} else if targetCenter.X > myCenter.X {
-		// Target is right from us
-		if player.Car.Direction != LEFT && player.checkOkSide(restPlayers, RIGHT) {
-			player.Car.Direction = RIGHT
-		} else if targetCenter.Y < myCenter.Y {
-			player.Car.Direction = UP
-		} else {
-			player.Car.Direction = DOWN
-		}
-	} else if targetCenter.Y < myCenter.Y && player.checkOkSide(restPlayers, UP) {
-		// Target is above us
-		if player.Car.Direction != DOWN {
-			player.Car.Direction = UP
-		} else if targetCenter.X > myCenter.X {
-			player.Car.Direction = RIGHT
-		} else {
-			player.Car.Direction = LEFT
-		}
-	} else if targetCenter.Y > myCenter.Y && player.checkOkSide(restPlayers, DOWN) {
-		// Target it below us
-		if player.Car.Direction != UP {
-			player.Car.Direction = DOWN
-		} else if targetCenter.X > myCenter.X {
-			player.Car.Direction = RIGHT
-		} else {
-			player.Car.Direction = LEFT
-		}
-	}
+func (player *Player) ownedBy(owner int64) bool {
+	return atomic.LoadInt64(&player.Owner) == owner
 }
 
+/*
+Asks the brain of the round's bot level what to do. The brain is replaced
+as soon as the level of the round is changed
+*/
 func (player *Player) moveBot(round *Round) {
+	level := ""
+	var brain BotBrain
 	for {
-		if player.Health <= 0 || round.State == FINISHED {
+		if player.Health <= 0 || round.State == FINISHED || !player.botControlled() {
 			return
 		}
-		rid := round.getRandomAliveNonBotPlayerId()
-		if rid == -1 {
-			continue
-		}
 
-		targetPlayer := &round.Players[rid]
-		allPlayersExceptMeAndTarget := round.getPlayersExcept([]Player{*targetPlayer, *player})
-		allPlayersExceptMe := append(allPlayersExceptMeAndTarget, targetPlayer)
-
-		heartRect := &Rectangle{Points: [4]Point{
-			{round.Bonus.X, round.Bonus.Y},
-			{round.Bonus.X, round.Bonus.Y},
-			{round.Bonus.X, round.Bonus.Y},
-			{round.Bonus.X, round.Bonus.Y}},
+		if level != round.BotLevel {
+			level = round.BotLevel
+			var err error
+			if brain, err = newBotBrain(level); err != nil {
+				player.logger(round).Error("Failed to create the bot", "err", err)
+				brain, _ = newBotBrain(defaultBotLevel)
+			}
 		}
-		targetCenter := &Point{}
-		for i := 0; i < 20; i++ {
-			if round.State == FINISHED || player.Health <= 0 {
-				return
-			} else if round.State == RUNNING {
-				// Check if BOT is throwing the bomb
-				if player.Bombs > 0 && rand.Int()%highFactor == 0 {
-					player.DropBomb = true
-				}
-
-				// Do not hunt dead player
-				if targetPlayer.Health <= 0 {
-					break
-				}
-				if player.Health <= 0 {
-					return
-				}
-
-				// Navigate Bot based on centers of cars
-				myCenter := &Point{
-					player.Car.Borders.Points[LEFTUP].X + (player.Car.Borders.Points[RIGHTUP].X-player.Car.Borders.Points[LEFTUP].X)/2,
-					player.Car.Borders.Points[LEFTUP].Y + (player.Car.Borders.Points[LEFTDOWN].Y-player.Car.Borders.Points[LEFTUP].Y)/2,
-				}
-
-				// Check if heart next to Bot
-				heartOnSide := player.Car.Borders.nextTo(heartRect, 5)
-				// Bot prefers to grab the heart
-				if heartOnSide != -1 && round.Bonus.X != -1 && round.Bonus.Y != -1 {
-					targetCenter = &Point{round.Bonus.X, round.Bonus.Y}
-				} else {
-					targetCenter = &Point{
-						targetPlayer.Car.Borders.Points[LEFTUP].X + (targetPlayer.Car.Borders.Points[RIGHTUP].X-targetPlayer.Car.Borders.Points[LEFTUP].X)/2,
-						targetPlayer.Car.Borders.Points[LEFTUP].Y + (targetPlayer.Car.Borders.Points[LEFTDOWN].Y-targetPlayer.Car.Borders.Points[LEFTUP].Y)/2,
-					}
-				}
 
-				player.navigateBot(myCenter, targetCenter, allPlayersExceptMe)
-
-			}
-			time.Sleep(time.Duration(500 * time.Millisecond))
+		if round.State == RUNNING {
+			player.applyBotAction(brain.Decide(round.snapshot(player)))
 		}
+		time.Sleep(brain.Interval())
 	}
 }
 
-func (player *Player) checkHitWall() bool {
+func (player *Player) checkHitWall(round *Round) bool {
 	for _, point := range player.Car.Borders.Points {
 		if point.X < 1 || point.X > mapWidth-nameTableWidth || point.Y < 1 || point.Y > mapHeight-1 {
 			player.Health -= DAMAGE_FRONT * player.Car.Speed
 			return true
 		}
 	}
+	if round.hitsWalls(&player.Car.Borders) {
+		player.Health -= DAMAGE_FRONT * player.Car.Speed
+		return true
+	}
+	// The car has already moved, the zone is a wall if it was inside before
+	before := player.Car
+	before.recalculateBorders(true)
+	if zone, ok := round.fence(&before.Borders); ok && !zone.containsRectangle(&player.Car.Borders) {
+		player.Health -= DAMAGE_FRONT * player.Car.Speed
+		return true
+	}
 	return false
 }
 
 func (player *Player) checkHitAnotherCar(round *Round) bool {
-	for _, opponent := range round.Players {
-		if player.Name == opponent.Name {
+	for num, opponent := range round.Players {
+		if player.Name == opponent.Name || opponent.respawning() || player.hasEffect(GHOST) || opponent.hasEffect(GHOST) {
 			continue
 		}
 
 		if player.Car.Borders.intersects(&opponent.Car.Borders) {
+			health := player.Health
 			switch player.Car.Borders.nextTo(&opponent.Car.Borders, 0) {
 			case LEFT:
 				// Player was hit from LEFT
@@ -326,7 +367,7 @@ func (player *Player) checkHitAnotherCar(round *Round) bool {
 					player.Health -= DAMAGE_FRONT * player.Car.Speed
 				case RIGHT:
 					// DAMAGE_BACK crash
-					player.Health -= DAMAGE_BACK * (maxSpeed - player.Car.Speed)
+					player.Health -= DAMAGE_BACK * max(maxSpeed-player.Car.Speed, 0)
 				case UP | DOWN:
 					// DAMAGE_SIDE crash
 					player.Health -= DAMAGE_SIDE
@@ -339,7 +380,7 @@ func (player *Player) checkHitAnotherCar(round *Round) bool {
 					player.Health -= DAMAGE_FRONT * player.Car.Speed
 				case LEFT:
 					// DAMAGE_BACK crash
-					player.Health -= DAMAGE_BACK * (maxSpeed - player.Car.Speed)
+					player.Health -= DAMAGE_BACK * max(maxSpeed-player.Car.Speed, 0)
 				case UP | DOWN:
 					// DAMAGE_SIDE crash
 					player.Health -= DAMAGE_SIDE
@@ -352,7 +393,7 @@ func (player *Player) checkHitAnotherCar(round *Round) bool {
 					player.Health -= DAMAGE_FRONT * player.Car.Speed
 				case DOWN:
 					// DAMAGE_BACK crash
-					player.Health -= DAMAGE_BACK * (maxSpeed - player.Car.Speed)
+					player.Health -= DAMAGE_BACK * max(maxSpeed-player.Car.Speed, 0)
 				case LEFT | RIGHT:
 					// DAMAGE_SIDE crash
 					player.Health -= DAMAGE_SIDE
@@ -365,7 +406,7 @@ func (player *Player) checkHitAnotherCar(round *Round) bool {
 					player.Health -= DAMAGE_FRONT * player.Car.Speed
 				case UP:
 					// DAMAGE_BACK crash
-					player.Health -= DAMAGE_BACK * (maxSpeed - player.Car.Speed)
+					player.Health -= DAMAGE_BACK * max(maxSpeed-player.Car.Speed, 0)
 				case LEFT | RIGHT:
 					// DAMAGE_SIDE crash
 					player.Health -= DAMAGE_SIDE
@@ -393,6 +434,13 @@ func (player *Player) checkHitAnotherCar(round *Round) bool {
 				// Back hit
 				player.Health -= DAMAGE_BACK * (player.Car.Speed - opponent.Car.Speed)
 			}
+
+			// Teammates bounce off each other without damage
+			if round.friendly(opponent.Team, player) {
+				player.Health = health
+			} else {
+				player.LastHitBy, player.LastHitAt = num, now().Unix()
+			}
 			return true
 		}
 	}
@@ -401,9 +449,9 @@ func (player *Player) checkHitAnotherCar(round *Round) bool {
 }
 
 func (player *Player) checkHit(round *Round) {
-	if player.checkHitWall() || player.checkHitAnotherCar(round) {
+	if player.checkHitWall(round) || player.checkHitAnotherCar(round) {
 		player.Car.recalculateBorders(true)
-		player.LastCrash = time.Now().Unix()
+		player.LastCrash = now().Unix()
 
 		// Bounce player to the opposite direction
 		switch player.Car.Direction {
@@ -419,23 +467,13 @@ func (player *Player) checkHit(round *Round) {
 	}
 }
 
-func (player *Player) checkHitBonus(round *Round) {
-	bonusRect := &Rectangle{Points: [4]Point{
-		{round.Bonus.X, round.Bonus.Y},
-		{round.Bonus.X, round.Bonus.Y},
-		{round.Bonus.X, round.Bonus.Y},
-		{round.Bonus.X, round.Bonus.Y}},
-	}
-	if player.Car.Borders.intersects(bonusRect) {
-		player.Health += bonusPoint
-		player.Car.Speed = maxSpeed
-		round.Bonus.X, round.Bonus.Y = -1, -1
-	}
-}
-
 func (player *Player) checkHitBomb(round *Round) {
 	round.Lock()
-	for bomb := range round.Bombs {
+	for _, bomb := range sortedPoints(round.Bombs) {
+		owner := round.Bombs[bomb]
+		if round.friendly(round.Players[owner].Team, player) {
+			continue
+		}
 		bombRect := &Rectangle{Points: [4]Point{
 			{bomb.X, bomb.Y},
 			{bomb.X, bomb.Y},
@@ -445,8 +483,9 @@ func (player *Player) checkHitBomb(round *Round) {
 
 		if player.Car.Borders.intersects(bombRect) {
 			player.Health -= bonusPoint
-			player.LastCrash = time.Now().Unix()
+			player.LastCrash = now().Unix()
 			player.Car.Speed = 1
+			player.LastHitBy, player.LastHitAt = owner, now().Unix()
 			delete(round.Bombs, bomb)
 		}
 	}
@@ -457,124 +496,176 @@ func (player *Player) checkPosition(round *Round) {
 	for {
 		if player.Health <= 0 || round.State == FINISHED {
 			return
-		} else if round.State == RUNNING {
-			// Move player
-			player.Car.recalculateBorders(false)
+		} else if round.State == RUNNING && !player.respawning() {
+			player.move(round)
+		}
+		time.Sleep(player.moveDelay())
+	}
+}
 
-			// Check if we catch the bonus
-			player.checkHitBonus(round)
+func (player *Player) move(round *Round) {
+	if player.frozen() {
+		return
+	}
+	health := player.Health
 
-			// Check if we hit the bomb
-			player.checkHitBomb(round)
+	// Move player
+	player.Car.recalculateBorders(false)
 
-			// Check if we hit something
-			player.checkHit(round)
-		}
+	// Check if we catch a power-up
+	player.checkHitPowerUps(round)
 
-		/*
-		 Because vertical symbols are 3x bigger, than horizontal, we need to slowdown recalculation of vertical objects
-		*/
-		slowerDown := int64(1)
-		if player.Car.Direction == UP || player.Car.Direction == DOWN {
-			slowerDown = 3
-		}
+	// Check if we hit the bomb
+	player.checkHitBomb(round)
+
+	// Check if we hit something
+	player.checkHit(round)
 
-		time.Sleep(time.Duration(slowerDown*150/player.Car.Speed) * time.Millisecond)
+	if player.invulnerable() && player.Health < health {
+		player.Health = health
+	} else if player.hasEffect(SHIELD) && player.Health < health {
+		// The shield takes a single hit
+		player.Health = health
+		player.Effects[SHIELD] = time.Time{}
+	}
+	if mode, ok := round.Mode.(respawnMode); ok && player.Health <= 0 {
+		mode.Eliminated(round, player)
 	}
 }
 
+func (player *Player) respawning() bool {
+	return !player.RespawnAt.IsZero()
+}
+
+func (player *Player) invulnerable() bool {
+	return now().Before(player.InvulnerableUntil)
+}
+
+func (player *Player) moveDelay() time.Duration {
+	/*
+	 Because vertical symbols are 3x bigger, than horizontal, we need to slowdown recalculation of vertical objects
+	*/
+	slowerDown := int64(1)
+	if player.Car.Direction == UP || player.Car.Direction == DOWN {
+		slowerDown = 3
+	}
+	return time.Duration(slowerDown*150/player.Car.Speed) * time.Millisecond
+}
+
 func (player *Player) checkSpeed(round *Round) {
 	for {
 		if player.Health <= 0 || round.State == FINISHED {
 			return
 		}
 
-		now := time.Now().Unix()
-		if now-player.LastCrash > player.Car.Speed*2 && player.Car.Speed < maxSpeed {
-			player.Car.Speed++
-		} else if now-player.LastCrash < 2 {
-			player.Car.Speed = 1
-		}
+		player.updateSpeed()
 		time.Sleep(1 % framesPerSecond * 100 * time.Millisecond)
 	}
 }
 
+func (player *Player) updateSpeed() {
+	sinceCrash := now().Unix() - player.LastCrash
+	if player.hasEffect(TURBO) && sinceCrash >= 2 {
+		player.Car.Speed = turboSpeed
+		return
+	} else if player.Car.Speed > maxSpeed {
+		player.Car.Speed = maxSpeed
+	}
+
+	if sinceCrash > player.Car.Speed*2 && player.Car.Speed < maxSpeed {
+		player.Car.Speed++
+	} else if sinceCrash < 2 {
+		player.Car.Speed = 1
+	}
+}
+
 func (player *Player) checkHealth(round *Round) {
 	for {
 		if player.Health <= 0 {
 			player.Health = 0
+			player.logger(round).Info("Player died", "event", eventDeath)
 			return
 		}
 
-		for num, player := range round.Players {
-			if player.Health > 100 {
-				round.Players[num].Health = 100
-			} else if player.Health <= 0 {
-				round.Players[num].Health = 0
-				round.Players[num].Color = BOLD
-			}
-		}
+		round.limitHealth()
 		time.Sleep(1 % framesPerSecond * 100 * time.Millisecond)
 	}
 }
 
+func (round *Round) limitHealth() {
+	for num, player := range round.Players {
+		if player.Health > 100 {
+			round.Players[num].Health = 100
+		} else if player.Health <= 0 {
+			round.Players[num].Health = 0
+			round.Players[num].Color = BOLD
+		}
+	}
+}
+
 func (player *Player) checkBomb(round *Round) {
 	for {
 		if player.Health <= 0 || round.State == FINISHED {
 			return
 		}
 
-		for num, player := range round.Players {
-			if player.DropBomb {
-				bombPosition := Point{}
+		round.placeBombs()
+		time.Sleep(1 % framesPerSecond * 100 * time.Millisecond)
+	}
+}
 
-				switch player.Car.Direction {
-				case LEFT:
-					bombPosition.X = player.Car.Borders.Points[RIGHTUP].X + 1
-					bombPosition.Y = player.Car.Borders.Points[RIGHTUP].Y + (player.Car.Borders.Points[RIGHTDOWN].Y-player.Car.Borders.Points[RIGHTUP].Y)/2
-				case RIGHT:
-					bombPosition.X = player.Car.Borders.Points[LEFTUP].X - 1
-					bombPosition.Y = player.Car.Borders.Points[RIGHTUP].Y + (player.Car.Borders.Points[RIGHTDOWN].Y-player.Car.Borders.Points[RIGHTUP].Y)/2
-				case UP:
-					bombPosition.X = player.Car.Borders.Points[LEFTUP].X + (player.Car.Borders.Points[RIGHTUP].X-player.Car.Borders.Points[LEFTUP].X)/2
-					bombPosition.Y = player.Car.Borders.Points[LEFTDOWN].Y + 1
-				case DOWN:
-					bombPosition.X = player.Car.Borders.Points[LEFTUP].X + (player.Car.Borders.Points[RIGHTUP].X-player.Car.Borders.Points[LEFTUP].X)/2
-					bombPosition.Y = player.Car.Borders.Points[LEFTUP].Y - 1
-				}
-				if bombPosition.X > 1 && bombPosition.X < mapWidth-nameTableWidth-1 && bombPosition.Y > 1 && bombPosition.Y < mapHeight-1 {
-					round.Players[num].DropBomb = false
-					round.Players[num].Bombs--
-					round.Lock()
-					round.Bombs[bombPosition] = true
-					round.Unlock()
-				}
-			} else if rand.Int()%(highFactor*lowFactor) == 0 && round.State == RUNNING {
-				round.Players[num].Bombs++
+// Drops requested bombs and gives new ones from time to time
+func (round *Round) placeBombs() {
+	for num, player := range round.Players {
+		if player.respawning() {
+			continue
+		} else if player.DropBomb {
+			bombPosition := Point{}
+
+			switch player.Car.Direction {
+			case LEFT:
+				bombPosition.X = player.Car.Borders.Points[RIGHTUP].X + 1
+				bombPosition.Y = player.Car.Borders.Points[RIGHTUP].Y + (player.Car.Borders.Points[RIGHTDOWN].Y-player.Car.Borders.Points[RIGHTUP].Y)/2
+			case RIGHT:
+				bombPosition.X = player.Car.Borders.Points[LEFTUP].X - 1
+				bombPosition.Y = player.Car.Borders.Points[RIGHTUP].Y + (player.Car.Borders.Points[RIGHTDOWN].Y-player.Car.Borders.Points[RIGHTUP].Y)/2
+			case UP:
+				bombPosition.X = player.Car.Borders.Points[LEFTUP].X + (player.Car.Borders.Points[RIGHTUP].X-player.Car.Borders.Points[LEFTUP].X)/2
+				bombPosition.Y = player.Car.Borders.Points[LEFTDOWN].Y + 1
+			case DOWN:
+				bombPosition.X = player.Car.Borders.Points[LEFTUP].X + (player.Car.Borders.Points[RIGHTUP].X-player.Car.Borders.Points[LEFTUP].X)/2
+				bombPosition.Y = player.Car.Borders.Points[LEFTUP].Y - 1
 			}
+			if bombPosition.X > 1 && bombPosition.X < mapWidth-nameTableWidth-1 && bombPosition.Y > 1 && bombPosition.Y < mapHeight-1 {
+				round.Players[num].DropBomb = false
+				round.Players[num].Bombs--
+				round.Lock()
+				round.Bombs[bombPosition] = num
+				round.Unlock()
+			}
+		} else if rand.Int()%(highFactor*lowFactor) == 0 && round.State == RUNNING && !round.NoBombs {
+			round.Players[num].Bombs++
 		}
-
-		time.Sleep(1 % framesPerSecond * 100 * time.Millisecond)
 	}
 }
 
-func (player *Player) writeToThePlayer(message []byte, clean bool) {
+func (player *Player) writeToThePlayer(round *Round, message []byte, clean bool) {
 	if clean {
 		_, err := player.Conn.Write(clear)
 		if err != nil {
 			// Kick user if connection got lost
-			player.Health = 0
+			player.lost(round, reasonWriteError)
 			return
 		}
 	}
 	_, err := player.Conn.Write(home)
 	if err != nil {
-		player.Health = 0
+		player.lost(round, reasonWriteError)
 		return
 	}
 	_, err = player.Conn.Write(message)
 	if err != nil {
-		player.Health = 0
+		player.lost(round, reasonWriteError)
 		return
 	}
 }
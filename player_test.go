@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+// The newcomer gets the car of the first living bot with nothing of its previous driver
+func TestClaimBot(t *testing.T) {
+	round := testRound(0, testCar{100, 0}, testCar{0, 1}, testCar{100, 2}, testCar{100, 3})
+	round.Players[1].Bot = true
+	bot := &round.Players[2]
+	bot.Name, bot.Bot, bot.Muted, bot.Afk, bot.AutoPilot, bot.Disconnected = "Muted human", true, true, true, true, 1
+	round.Players[3].Bot = true
+
+	newcomer := Player{Name: "New", Token: "token"}
+	claimed, replaced := newcomer.claimBot(round)
+	if claimed != bot || replaced != "Muted human" {
+		t.Fatalf("Claimed %v replacing %q, want the living bot", claimed, replaced)
+	}
+	if bot.Name != "New" || bot.Token != "token" || bot.Bot || bot.Muted || bot.Afk || bot.AutoPilot || bot.waitingReconnect() {
+		t.Errorf("Claimed car keeps the state of the previous driver: %+v", bot)
+	}
+	if bot.ownedBy(0) {
+		t.Error("Goroutines of the previous owner keep the car")
+	}
+
+	if claimed, _ := (&Player{Name: "Next"}).claimBot(round); claimed != &round.Players[3] {
+		t.Errorf("Second newcomer claimed %v, want the other bot", claimed)
+	}
+}
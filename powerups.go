@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Power-ups lie on the arena until a car drives over them. The heart repairs
+and speeds the car up at once like it always did, the rest give the car an
+effect for a while. The time left of every effect is shown in the sidebar
+next to the bombs
+*/
+
+// Kinds of power-ups
+const (
+	HEART = iota
+	SHIELD
+	TURBO
+	BOMB_PACK
+	REPAIR
+	GHOST
+	EMP
+	powerUpKinds
+)
+
+type powerUp struct {
+	Name  string
+	Glyph string
+	Color int
+	// How long the effect lasts, 0 for the ones applied at once
+	Duration time.Duration
+}
+
+var powerUps = [powerUpKinds]powerUp{
+	HEART:     {"heart", bonus, RED, 0},
+	SHIELD:    {"shield", "\xE2\x97\x88", BLUE, 15 * time.Second},
+	TURBO:     {"turbo", "\xC2\xBB", YELLOW, 5 * time.Second},
+	BOMB_PACK: {"bombs", "\xE2\x9C\xB9", BOLD, 0},
+	REPAIR:    {"repair", "\xE2\x9C\x9A", GREEN, 3 * time.Second},
+	GHOST:     {"ghost", "\xE2\x97\x8C", DIM, 5 * time.Second},
+	// The effect of the EMP is on the frozen cars around the one which took it
+	EMP: {"emp", "\xE2\x86\xAF", CYAN, 3 * time.Second},
+}
+
+const defaultPowerUpWeights = "heart=6,shield=2,turbo=2,bombs=2,repair=2,ghost=1,emp=1"
+
+// Power-ups on the arena at once
+const maxPowerUps = 3
+
+// Speed of the car with turbo, above maxSpeed
+const turboSpeed = maxSpeed + 2
+
+const bombPackBombs = 3
+
+// Health repaired every frame
+const repairHealth = 1
+
+// Cars closer than this to the one which took the EMP freeze, vertical distance is 3x bigger
+const empRadius = 30
+
+/*
+Parses weights like heart=6,shield=2: power-ups spawn with the chance of
+their weight to the sum of weights. Kinds which are not listed never spawn
+*/
+func parsePowerUpWeights(s string) ([powerUpKinds]int, error) {
+	var weights [powerUpKinds]int
+	if s == "" {
+		return weights, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return weights, fmt.Errorf("Power-up weight %q is not name=weight", item)
+		}
+		kind := powerUpKind(name)
+		if kind < 0 {
+			return weights, fmt.Errorf("Unknown power-up %q, known are %s", name, strings.Join(powerUpNames(), ", "))
+		}
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 0 {
+			return weights, fmt.Errorf("Weight of power-up %s must be a non-negative number", name)
+		}
+		weights[kind] = weight
+	}
+	return weights, nil
+}
+
+func powerUpKind(name string) int {
+	for kind, p := range powerUps {
+		if p.Name == name {
+			return kind
+		}
+	}
+	return -1
+}
+
+func powerUpNames() []string {
+	var names []string
+	for _, p := range powerUps {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// Random kind by the weights, -1 if all of them are 0
+func randomPowerUp(weights [powerUpKinds]int) int {
+	total := 0
+	for _, weight := range weights {
+		total += weight
+	}
+	if total == 0 {
+		return -1
+	}
+	n := rand.Intn(total)
+	for kind, weight := range weights {
+		if n < weight {
+			return kind
+		}
+		n -= weight
+	}
+	return -1
+}
+
+func (round *Round) applyPowerUps(activeFrameBuffer []Symbol) {
+	if round.State == STARTING {
+		return
+	}
+	round.spawnPowerUp()
+	round.repairCars()
+
+	round.Lock()
+	for p, kind := range round.PowerUps {
+		activeFrameBuffer[p.Y*mapWidth+p.X] = Symbol{powerUps[kind].Color, []byte(powerUps[kind].Glyph)}
+	}
+	round.Unlock()
+}
+
+func (round *Round) spawnPowerUp() {
+	if rand.Int()%lowFactor != 0 {
+		return
+	}
+	kind := randomPowerUp(round.PowerUpWeights)
+	p := Point{rand.Intn(mapWidth-nameTableWidth-2) + 1, rand.Intn(mapHeight-2) + 1}
+	if kind < 0 || round.Walls[p] {
+		return
+	}
+
+	round.Lock()
+	if len(round.PowerUps) < maxPowerUps {
+		round.PowerUps[p] = kind
+	}
+	round.Unlock()
+}
+
+func (round *Round) repairCars() {
+	for num := range round.Players {
+		p := &round.Players[num]
+		if p.Health > 0 && p.hasEffect(REPAIR) {
+			p.Health += repairHealth
+		}
+	}
+}
+
+func (player *Player) checkHitPowerUps(round *Round) {
+	round.Lock()
+	defer round.Unlock()
+	for _, p := range sortedPoints(round.PowerUps) {
+		if player.Car.Borders.contains(p) {
+			kind := round.PowerUps[p]
+			delete(round.PowerUps, p)
+			player.takePowerUp(round, kind)
+		}
+	}
+}
+
+func (player *Player) takePowerUp(round *Round, kind int) {
+	switch kind {
+	case HEART:
+		player.Health += bonusPoint
+		player.Car.Speed = maxSpeed
+	case BOMB_PACK:
+		player.Bombs += bombPackBombs
+	case TURBO:
+		player.Car.Speed = turboSpeed
+		player.Effects[kind] = now().Add(powerUps[kind].Duration)
+	case EMP:
+		center := player.Car.Borders.center()
+		for num := range round.Players {
+			other := &round.Players[num]
+			c := other.Car.Borders.center()
+			if other == player || round.friendly(player.Team, other) ||
+				abs(c.X-center.X)+verticalCost*abs(c.Y-center.Y) > empRadius {
+				continue
+			}
+			other.Effects[kind] = now().Add(powerUps[kind].Duration)
+		}
+	default:
+		player.Effects[kind] = now().Add(powerUps[kind].Duration)
+	}
+	player.logger(round).Debug("Player took the power-up", "power_up", powerUps[kind].Name)
+}
+
+func (player *Player) hasEffect(kind int) bool {
+	return now().Before(player.Effects[kind])
+}
+
+// Cars hit by the EMP can't move
+func (player *Player) frozen() bool {
+	return player.hasEffect(EMP)
+}
+
+// Active effects with the seconds left, like ◈12 »4
+func (player *Player) effectsStatus() string {
+	var effects []string
+	for kind, p := range powerUps {
+		if player.hasEffect(kind) {
+			effects = append(effects, fmt.Sprintf("%s%d", p.Glyph, int(player.Effects[kind].Sub(now()).Seconds())+1))
+		}
+	}
+	return strings.Join(effects, " ")
+}
@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestParsePowerUpWeights(t *testing.T) {
+	tests := []struct {
+		s       string
+		weights [powerUpKinds]int
+		err     bool
+	}{
+		{"", [powerUpKinds]int{}, false},
+		{defaultPowerUpWeights, [powerUpKinds]int{HEART: 6, SHIELD: 2, TURBO: 2, BOMB_PACK: 2, REPAIR: 2, GHOST: 1, EMP: 1}, false},
+		{"heart=1", [powerUpKinds]int{HEART: 1}, false},
+		{" shield=3, emp=0 ", [powerUpKinds]int{SHIELD: 3}, false},
+		{"turbo=1,turbo=4", [powerUpKinds]int{TURBO: 4}, false},
+		{"heart", [powerUpKinds]int{}, true},
+		{"heart=", [powerUpKinds]int{}, true},
+		{"heart=-1", [powerUpKinds]int{}, true},
+		{"heart=x", [powerUpKinds]int{}, true},
+		{"rocket=1", [powerUpKinds]int{}, true},
+		{"Heart=1", [powerUpKinds]int{}, true},
+		{"heart=1,", [powerUpKinds]int{}, true},
+	}
+	for _, test := range tests {
+		weights, err := parsePowerUpWeights(test.s)
+		if test.err {
+			if err == nil {
+				t.Errorf("parsePowerUpWeights(%q) = %v, want an error", test.s, weights)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePowerUpWeights(%q) failed: %v", test.s, err)
+		} else if weights != test.weights {
+			t.Errorf("parsePowerUpWeights(%q) = %v, want %v", test.s, weights, test.weights)
+		}
+	}
+}
+
+func TestRandomPowerUp(t *testing.T) {
+	tests := []struct {
+		weights [powerUpKinds]int
+		kind    int
+	}{
+		{[powerUpKinds]int{}, -1},
+		{[powerUpKinds]int{GHOST: 5}, GHOST},
+		{[powerUpKinds]int{HEART: 0, EMP: 1}, EMP},
+	}
+	for _, test := range tests {
+		for range 100 {
+			if kind := randomPowerUp(test.weights); kind != test.kind {
+				t.Errorf("randomPowerUp(%v) = %d, want %d", test.weights, kind, test.kind)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// http://www.haproxy.org/download/1.8/doc/proxy-protocol.txt
+const proxyHeaderTimeout = 5 * time.Second
+const proxyV1MaxLength = 107
+
+var proxyV1Prefix = []byte("PROXY ")
+var proxyV2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+/*
+proxyConn reads the rest of the stream after the header from the buffer
+and reports the address of the real client
+*/
+type proxyConn struct {
+	net.Conn
+	reader *bufio.Reader
+	remote net.Addr
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+/*
+Reads PROXY protocol v1 or v2 header. LOCAL and UNKNOWN connections
+(e.g. health checks) keep the address of the proxy
+*/
+func readProxyHeader(conn net.Conn) (net.Conn, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout)); err != nil {
+		return nil, err
+	}
+	defer conn.SetReadDeadline(time.Time{})
+
+	reader := bufio.NewReader(conn)
+	pc := &proxyConn{Conn: conn, reader: reader, remote: conn.RemoteAddr()}
+
+	signature, err := reader.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, err
+	}
+
+	var remote net.Addr
+	if bytes.Equal(signature, proxyV2Signature) {
+		remote, err = readProxyV2(reader)
+	} else if bytes.HasPrefix(signature, proxyV1Prefix) {
+		remote, err = readProxyV1(reader)
+	} else {
+		return nil, errors.New("PROXY header is missing")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if remote != nil {
+		pc.remote = remote
+	}
+	return pc, nil
+}
+
+func readProxyV1(reader *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyV1MaxLength {
+			return nil, errors.New("PROXY v1 header is too long")
+		}
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+	}
+
+	// PROXY TCP4 255.255.255.255 255.255.255.255 65535 65535
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("Bad PROXY v1 header %q", line)
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, fmt.Errorf("Bad PROXY v1 source %s:%s", fields[2], fields[4])
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+func readProxyV2(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+
+	version, command := header[12]>>4, header[12]&0x0F
+	family := header[13] >> 4
+	length := binary.BigEndian.Uint16(header[14:16])
+	if version != 2 {
+		return nil, fmt.Errorf("Bad PROXY version %d", version)
+	}
+
+	addresses := make([]byte, length)
+	if _, err := io.ReadFull(reader, addresses); err != nil {
+		return nil, err
+	}
+
+	// LOCAL command
+	if command == 0 {
+		return nil, nil
+	}
+
+	switch family {
+	case 1:
+		// AF_INET: src addr(4), dst addr(4), src port(2), dst port(2)
+		if length < 12 {
+			return nil, errors.New("PROXY v2 IPv4 addresses are too short")
+		}
+		return &net.TCPAddr{IP: net.IP(addresses[0:4]), Port: int(binary.BigEndian.Uint16(addresses[8:10]))}, nil
+	case 2:
+		// AF_INET6: src addr(16), dst addr(16), src port(2), dst port(2)
+		if length < 36 {
+			return nil, errors.New("PROXY v2 IPv6 addresses are too short")
+		}
+		return &net.TCPAddr{IP: net.IP(addresses[0:16]), Port: int(binary.BigEndian.Uint16(addresses[32:34]))}, nil
+	}
+	// AF_UNSPEC and AF_UNIX don't carry an IP
+	return nil, nil
+}
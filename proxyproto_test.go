@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+func proxyV2Header(command, family byte, addresses []byte) []byte {
+	header := append(bytes.Clone(proxyV2Signature), 0x20|command, family<<4|1)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addresses)))
+	return append(header, addresses...)
+}
+
+// Source address and port, then the destination ones
+func proxyV2Addresses(src, dst net.IP, srcPort, dstPort uint16) []byte {
+	addresses := append(bytes.Clone(src), dst...)
+	addresses = binary.BigEndian.AppendUint16(addresses, srcPort)
+	return binary.BigEndian.AppendUint16(addresses, dstPort)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	ipv4 := proxyV2Addresses(net.IP{192, 0, 2, 1}, net.IP{198, 51, 100, 1}, 5678, 23)
+	ipv6 := proxyV2Addresses(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), 5678, 23)
+	tests := []struct {
+		name   string
+		header []byte
+		remote string
+		err    bool
+	}{
+		{"v1 TCP4", []byte("PROXY TCP4 192.0.2.1 198.51.100.1 5678 23\r\n"), "192.0.2.1:5678", false},
+		{"v1 TCP6", []byte("PROXY TCP6 2001:db8::1 2001:db8::2 5678 23\r\n"), "[2001:db8::1]:5678", false},
+		{"v1 UNKNOWN", []byte("PROXY UNKNOWN\r\n"), "pipe", false},
+		{"v1 unknown family", []byte("PROXY UDP4 192.0.2.1 198.51.100.1 5678 23\r\n"), "", true},
+		{"v1 bad address", []byte("PROXY TCP4 192.0.2 198.51.100.1 5678 23\r\n"), "", true},
+		{"v1 bad port", []byte("PROXY TCP4 192.0.2.1 198.51.100.1 65536 23\r\n"), "", true},
+		{"v1 too long", append([]byte("PROXY TCP4 "), bytes.Repeat([]byte{'1'}, proxyV1MaxLength)...), "", true},
+		{"v1 truncated", []byte("PROXY TCP4 192.0.2.1 198.51"), "", true},
+		{"v2 IPv4", proxyV2Header(1, 1, ipv4), "192.0.2.1:5678", false},
+		{"v2 IPv6", proxyV2Header(1, 2, ipv6), "[2001:db8::1]:5678", false},
+		{"v2 LOCAL", proxyV2Header(0, 1, ipv4), "pipe", false},
+		{"v2 unknown family", proxyV2Header(1, 7, []byte{1, 2, 3}), "pipe", false},
+		{"v2 short IPv4", proxyV2Header(1, 1, ipv4[:8]), "", true},
+		{"v2 short IPv6", proxyV2Header(1, 2, ipv6[:20]), "", true},
+		{"v2 truncated header", proxyV2Header(1, 1, ipv4)[:14], "", true},
+		{"v2 truncated addresses", proxyV2Header(1, 1, ipv4)[:20], "", true},
+		{"v2 bad version", append(bytes.Clone(proxyV2Signature), 0x31, 0x11, 0, 0), "", true},
+		{"missing", []byte("Bob\r\nsome more input\r\n"), "", true},
+	}
+	for _, test := range tests {
+		client, server := net.Pipe()
+		go func() {
+			client.Write(append(test.header, "hello"...))
+			client.Close()
+		}()
+
+		conn, err := readProxyHeader(server)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: header from %s is accepted, want an error", test.name, conn.RemoteAddr())
+			}
+			server.Close()
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			server.Close()
+			continue
+		}
+		if remote := conn.RemoteAddr().String(); remote != test.remote {
+			t.Errorf("%s: remote address %s, want %s", test.name, remote, test.remote)
+		}
+		// The stream after the header belongs to the game
+		if rest, err := io.ReadAll(conn); err != nil || string(rest) != "hello" {
+			t.Errorf("%s: the rest of the stream is %q, %v", test.name, rest, err)
+		}
+		server.Close()
+	}
+}
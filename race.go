@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+Race: cars start from the grid of the track and pass its checkpoints in
+order. Crashes slow the car down like in the deathmatch and bombs are
+optional hazards. After the first car finishes the rest have
+raceFinishWindow to finish too, then the round is over
+*/
+
+const raceFinishWindow = 30 * time.Second
+
+type racer struct {
+	// Index of the checkpoint the car drives to
+	Next     int
+	Laps     int
+	Started  bool
+	LapStart time.Time
+	LastLap  time.Duration
+	// Time of the lap at the last passed checkpoint and its index
+	Split           time.Duration
+	SplitCheckpoint int
+	// Time of the whole race, 0 while the car is racing
+	Finished time.Duration
+	Place    int
+}
+
+type raceMode struct {
+	track     *raceTrack
+	laps      int
+	bombs     bool
+	racers    []racer
+	started   time.Time
+	finishers []int
+}
+
+func (m *raceMode) Start(round *Round) {
+	round.Walls = m.track.Walls
+	round.NoBombs = !m.bombs
+	for num := range round.Players {
+		p := &round.Players[num]
+		start := m.track.Starts[num]
+		p.Car.Borders = start.borders()
+		p.Car.Direction = start.Direction
+		if round.NoBombs {
+			p.Bombs = 0
+		}
+	}
+	m.racers = make([]racer, len(round.Players))
+}
+
+func (m *raceMode) Apply(round *Round, activeFrameBuffer []Symbol) {
+	if round.State == RUNNING {
+		if m.started.IsZero() {
+			m.started = now()
+		}
+		for num := range round.Players {
+			m.play(round, num)
+		}
+	}
+
+	// Tracks have at most 9 checkpoints, the digits of the track file
+	for i, checkpoint := range m.track.Checkpoints {
+		drawWhereEmpty(activeFrameBuffer, checkpoint, Symbol{BOLD, []byte{byte('1' + i)}})
+	}
+
+	for num, r := range m.racers {
+		var progress, times string
+		if r.Finished > 0 {
+			progress = fmt.Sprintf("Finished %s", ordinal(r.Place))
+			times = formatRaceTime(r.Finished)
+		} else {
+			progress = fmt.Sprintf("Lap %d/%d CP %d/%d", min(r.Laps+1, m.laps), m.laps, r.Next+1, len(m.track.Checkpoints))
+			if r.Started {
+				times = formatRaceTime(now().Sub(r.LapStart))
+			}
+			if r.LastLap > 0 {
+				times += " last " + formatRaceTime(r.LastLap)
+			}
+		}
+		round.applySidebarLine(activeFrameBuffer, num, 4, progress, round.Players[num].Color)
+		round.applySidebarLine(activeFrameBuffer, num, 5, times, round.Players[num].Color)
+		// Five cars of four teams leave no room for the split
+		if r.Finished == 0 && r.Split > 0 && round.sidebarBlock() > 6 {
+			split := fmt.Sprintf("CP %d split %s", r.SplitCheckpoint+1, formatRaceTime(r.Split))
+			round.applySidebarLine(activeFrameBuffer, num, 6, split, round.Players[num].Color)
+		}
+	}
+}
+
+// Checks if the car reached the next checkpoint
+func (m *raceMode) play(round *Round, num int) {
+	p := &round.Players[num]
+	r := &m.racers[num]
+	if p.Health <= 0 || r.Finished > 0 || !m.passes(p, r.Next) {
+		return
+	}
+
+	passed := r.Next
+	r.Next = (r.Next + 1) % len(m.track.Checkpoints)
+	if r.Started {
+		r.Split, r.SplitCheckpoint = now().Sub(r.LapStart), passed
+	}
+	if r.Next != 1 {
+		return
+	}
+
+	// The start and finish line
+	if !r.Started {
+		r.Started = true
+		r.LapStart = now()
+		return
+	}
+	r.Laps++
+	r.LastLap = now().Sub(r.LapStart)
+	r.LapStart = now()
+	if r.Laps < m.laps {
+		return
+	}
+
+	m.finishers = append(m.finishers, num)
+	r.Place = len(m.finishers)
+	r.Finished = now().Sub(m.started)
+	round.broadcast(fmt.Sprintf("%s finished %s in %s!", p.displayName(num), ordinal(r.Place), formatRaceTime(r.Finished)))
+	p.logger(round).Info("Player finished the race", "place", r.Place, "time", r.Finished)
+}
+
+func (m *raceMode) passes(p *Player, checkpoint int) bool {
+	for _, point := range m.track.Checkpoints[checkpoint] {
+		if p.Car.Borders.contains(point) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+The first finisher wins when the others finished or crashed,
+or when raceFinishWindow is over
+*/
+func (m *raceMode) Winner(round *Round) string {
+	if len(m.finishers) == 0 {
+		return ""
+	}
+	first := m.finishers[0]
+	if now().Sub(m.started)-m.racers[first].Finished > raceFinishWindow {
+		return round.Players[first].displayName(first)
+	}
+	for num, p := range round.Players {
+		if p.Health > 0 && m.racers[num].Finished == 0 {
+			return ""
+		}
+	}
+	return round.Players[first].displayName(first)
+}
+
+// Bots drive to the middle of the next checkpoint
+func (m *raceMode) Goal(round *Round, num int) (Point, bool) {
+	if m.racers == nil || m.racers[num].Finished > 0 {
+		return Point{}, false
+	}
+	checkpoint := m.track.Checkpoints[m.racers[num].Next]
+	return checkpoint[len(checkpoint)/2], true
+}
+
+func formatRaceTime(d time.Duration) string {
+	return fmt.Sprintf("%d:%04.1f", int(d.Minutes()), (d % time.Minute).Seconds())
+}
+
+func ordinal(n int) string {
+	switch n {
+	case 1:
+		return "1st"
+	case 2:
+		return "2nd"
+	case 3:
+		return "3rd"
+	}
+	return fmt.Sprintf("%dth", n)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRaceProgress(t *testing.T) {
+	track := &raceTrack{Checkpoints: [][]Point{{{10, 10}}, {{40, 10}}, {{70, 10}}}}
+	tests := []struct {
+		name     string
+		laps     int
+		health   int64
+		passed   []int
+		next     int
+		lap      int
+		lastLap  time.Duration
+		finished time.Duration
+		// Lap time at the last passed checkpoint
+		split           time.Duration
+		splitCheckpoint int
+	}{
+		{"grid", 2, 100, nil, 0, 0, 0, 0, 0, 0},
+		{"start line", 2, 100, []int{0}, 1, 0, 0, 0, 0, 0},
+		{"checkpoint before the start", 2, 100, []int{1}, 0, 0, 0, 0, 0, 0},
+		{"skipped checkpoint", 2, 100, []int{0, 2}, 1, 0, 0, 0, 0, 0},
+		{"split", 2, 100, []int{0, 1}, 2, 0, 0, 0, 10 * time.Second, 1},
+		{"second split", 2, 100, []int{0, 1, 2}, 0, 0, 0, 0, 20 * time.Second, 2},
+		{"lap", 2, 100, []int{0, 1, 2, 0}, 1, 1, 30 * time.Second, 0, 30 * time.Second, 0},
+		{"finish", 1, 100, []int{0, 1, 2, 0}, 1, 1, 30 * time.Second, 40 * time.Second, 30 * time.Second, 0},
+		{"wreck", 2, 0, []int{0, 1}, 0, 0, 0, 0, 0, 0},
+	}
+	for _, test := range tests {
+		round := testRound(0, testCar{test.health, 0})
+		m := &raceMode{track: track, laps: test.laps, racers: make([]racer, 1), started: testStart}
+		for i, checkpoint := range test.passed {
+			setNow(t, testStart.Add(time.Duration(i+1)*10*time.Second))
+			driveTo(round, 0, track.Checkpoints[checkpoint][0])
+			m.play(round, 0)
+		}
+
+		r := m.racers[0]
+		if r.Next != test.next || r.Laps != test.lap || r.LastLap != test.lastLap || r.Finished != test.finished {
+			t.Errorf("%s: checkpoint %d, lap %d, last lap %s, finished in %s, want %d, %d, %s and %s", test.name,
+				r.Next, r.Laps, r.LastLap, r.Finished, test.next, test.lap, test.lastLap, test.finished)
+		}
+		if r.Split != test.split || r.SplitCheckpoint != test.splitCheckpoint {
+			t.Errorf("%s: split %s at checkpoint %d, want %s at %d", test.name, r.Split, r.SplitCheckpoint+1, test.split, test.splitCheckpoint+1)
+		}
+		if finishers := len(m.finishers); (test.finished > 0) != (finishers == 1) {
+			t.Errorf("%s: %d finishers", test.name, finishers)
+		}
+	}
+}
+
+func TestRaceWinner(t *testing.T) {
+	tests := []struct {
+		name      string
+		round     *Round
+		finished  []time.Duration
+		finishers []int
+		elapsed   time.Duration
+		winner    string
+	}{
+		{"racing", testRound(0, testCar{100, 0}, testCar{100, 1}), []time.Duration{0, 0}, nil, time.Minute, ""},
+		{"waiting for the rest", testRound(0, testCar{100, 0}, testCar{100, 1}), []time.Duration{0, time.Minute}, []int{1}, time.Minute + 10*time.Second, ""},
+		{"finish window is over", testRound(0, testCar{100, 0}, testCar{100, 1}), []time.Duration{0, time.Minute}, []int{1}, time.Minute + 31*time.Second, "B"},
+		{"everybody finished", testRound(0, testCar{100, 0}, testCar{100, 1}), []time.Duration{time.Minute + time.Second, time.Minute}, []int{1, 0}, time.Minute + time.Second, "B"},
+		{"the rest is wrecked", testRound(0, testCar{0, 0}, testCar{100, 1}, testCar{0, 2}), []time.Duration{0, time.Minute, 0}, []int{1}, time.Minute, "B"},
+	}
+	for _, test := range tests {
+		m := &raceMode{started: testStart, finishers: test.finishers}
+		for _, finished := range test.finished {
+			m.racers = append(m.racers, racer{Finished: finished})
+		}
+		setNow(t, testStart.Add(test.elapsed))
+		if winner := m.Winner(test.round); winner != test.winner {
+			t.Errorf("%s: winner %q, want %q", test.name, winner, test.winner)
+		}
+	}
+}
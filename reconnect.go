@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Long enough to not be guessed while the car waits for the player
+const resumeTokenBytes = 16
+
+var errWrongToken = errors.New("Wrong resume token")
+var errResumeTooLate = errors.New("The car is already given to the bot")
+
+func newResumeToken() string {
+	token := make([]byte, resumeTokenBytes)
+	rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+/*
+Called when the connection of the human is lost. During the running round
+the car is driven by the bot for ReconnectGrace, so the player can come back
+with the same name and the resume token. Otherwise the player is dead
+*/
+func (player *Player) lost(round *Round, reason string) {
+	disconnect(player.Conn, reason)
+	if player.Bot || player.Health <= 0 {
+		return
+	}
+
+	// External bots just connect again for the next round
+	if conf.ReconnectGrace <= 0 || player.External || (round.State != STARTING && round.State != RUNNING) {
+		player.Health = 0
+		return
+	}
+
+	since := now().UnixNano()
+	if !atomic.CompareAndSwapInt64(&player.Disconnected, 0, since) {
+		// Already waiting for the reconnect
+		return
+	}
+	player.logger(round).Info("Player lost the connection, the bot drives the car", "grace", conf.ReconnectGrace)
+	// The car of the AFK player is driven by the bot already
+	if !player.AutoPilot {
+		player.AutoPilot = true
+		go player.moveBot(round)
+	}
+	go player.waitReconnect(round, since)
+}
+
+// After the grace period the car stays with the bot till the end of the round
+func (player *Player) waitReconnect(round *Round, since int64) {
+	owner := atomic.LoadInt64(&player.Owner)
+	time.Sleep(conf.ReconnectGrace)
+
+	// Under the lock of the round, like newcomers take over bots
+	round.Lock()
+	defer round.Unlock()
+	if player.Health <= 0 || round.State == FINISHED || !player.ownedBy(owner) {
+		return
+	}
+	// The player is not waited anymore, unless resumed meanwhile
+	if !atomic.CompareAndSwapInt64(&player.Disconnected, since, 0) {
+		return
+	}
+	player.logger(round).Info("Player didn't reconnect, the car is given to the bot")
+	player.Bot = true
+	player.AutoPilot = false
+	atomic.AddInt64(&player.Owner, 1)
+	botsCount.Inc()
+}
+
+func (player *Player) waitingReconnect() bool {
+	return atomic.LoadInt64(&player.Disconnected) != 0
+}
+
+func findDisconnectedPlayer(name string) (*Round, *Player) {
+	for _, round := range registry.list() {
+		if round.State != STARTING && round.State != RUNNING {
+			continue
+		}
+		p := round.findPlayer(name)
+		if p != nil && !p.Bot && p.Health > 0 && p.waitingReconnect() {
+			return round, p
+		}
+	}
+	return nil, nil
+}
+
+func (player *Player) reconnectFooter() []byte {
+	return []byte(fmt.Sprintf("\r\nIf you get disconnected, come back as %s with the resume token %s", player.Name, player.Token))
+}
+
+/*
+Asks the token and gives the car back to the player
+*/
+func resume(conn net.Conn, round *Round, player *Player) error {
+	_, err := conn.Write([]byte("\r\nYour car is still in the game. Enter the resume token: "))
+	if err != nil {
+		return errCommunication
+	}
+
+	if conf.NameTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(conf.NameTimeout))
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return fmt.Errorf("%w: %w", errCommunication, err)
+	}
+
+	token := strings.TrimSpace(string(stripTelnetCommands([]byte(line))))
+	if subtle.ConstantTimeCompare([]byte(token), []byte(player.Token)) != 1 {
+		return errWrongToken
+	}
+	// The grace period may be over while the token was typed
+	if since := atomic.LoadInt64(&player.Disconnected); since == 0 || !atomic.CompareAndSwapInt64(&player.Disconnected, since, 0) {
+		return errResumeTooLate
+	}
+
+	player.Conn = conn
+	player.Afk = false
+	player.AutoPilot = false
+	player.touch()
+	annotateConn(conn, "round", round.Id)
+	player.logger(round).Info("Player reconnected")
+
+	conn.Write(clear)
+	player.readDirection(round)
+	return nil
+}
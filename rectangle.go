@@ -1,5 +1,11 @@
 package main
 
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
 type Rectangle struct {
 	Points [4]Point // LeftUP, RightUP, RightDOWN, LeftDOWN
 }
@@ -13,3 +19,56 @@ func (rectangle *Rectangle) intersects(r *Rectangle) bool {
 	}
 	return true
 }
+
+func newRectangle(x, y, width, height int) Rectangle {
+	return Rectangle{[4]Point{
+		{x, y},
+		{x + width - 1, y},
+		{x + width - 1, y + height - 1},
+		{x, y + height - 1}}}
+}
+
+func (rectangle *Rectangle) contains(p Point) bool {
+	return p.X >= rectangle.Points[LEFTUP].X && p.X <= rectangle.Points[RIGHTDOWN].X &&
+		p.Y >= rectangle.Points[LEFTUP].Y && p.Y <= rectangle.Points[RIGHTDOWN].Y
+}
+
+func (rectangle *Rectangle) containsRectangle(r *Rectangle) bool {
+	for _, p := range r.Points {
+		if !rectangle.contains(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func (rectangle *Rectangle) center() Point {
+	return Point{
+		rectangle.Points[LEFTUP].X + (rectangle.Points[RIGHTUP].X-rectangle.Points[LEFTUP].X)/2,
+		rectangle.Points[LEFTUP].Y + (rectangle.Points[LEFTDOWN].Y-rectangle.Points[LEFTUP].Y)/2,
+	}
+}
+
+// Points on the border of the rectangle
+func (rectangle *Rectangle) edge() []Point {
+	var points []Point
+	for y := rectangle.Points[LEFTUP].Y; y <= rectangle.Points[RIGHTDOWN].Y; y++ {
+		for x := rectangle.Points[LEFTUP].X; x <= rectangle.Points[RIGHTDOWN].X; x++ {
+			if y == rectangle.Points[LEFTUP].Y || y == rectangle.Points[RIGHTDOWN].Y ||
+				x == rectangle.Points[LEFTUP].X || x == rectangle.Points[RIGHTDOWN].X {
+				points = append(points, Point{x, y})
+			}
+		}
+	}
+	return points
+}
+
+/*
+Points of the map row by row. Maps are ranged in random order, but the
+tournament must play the same rounds with the same seed
+*/
+func sortedPoints[V any](m map[Point]V) []Point {
+	return slices.SortedFunc(maps.Keys(m), func(a, b Point) int {
+		return cmp.Or(cmp.Compare(a.Y, b.Y), cmp.Compare(a.X, b.X))
+	})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+/*
+Registry keeps all rounds from the moment they are created till they are over,
+so operators can find them by ID
+*/
+type roundRegistry struct {
+	sync.Mutex
+	rounds map[int]*Round
+}
+
+var registry = roundRegistry{rounds: make(map[int]*Round)}
+
+func (r *roundRegistry) add(round *Round) {
+	r.Lock()
+	r.rounds[round.Id] = round
+	r.Unlock()
+}
+
+func (r *roundRegistry) remove(id int) {
+	r.Lock()
+	delete(r.rounds, id)
+	r.Unlock()
+}
+
+func (r *roundRegistry) get(id int) *Round {
+	r.Lock()
+	defer r.Unlock()
+	return r.rounds[id]
+}
+
+func (r *roundRegistry) list() []*Round {
+	r.Lock()
+	rounds := make([]*Round, 0, len(r.rounds))
+	for _, round := range r.rounds {
+		rounds = append(rounds, round)
+	}
+	r.Unlock()
+
+	sort.Slice(rounds, func(i, j int) bool { return rounds[i].Id < rounds[j].Id })
+	return rounds
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
+	"strings"
 	"sync"
 	"time"
 )
@@ -11,12 +13,51 @@ type Round struct {
 	Players         []Player
 	Id, State       int
 	LastStateChange time.Time
-	Bonus           Point
-	Bombs           map[Point]bool
-	FrameBuffer     Symbols
+	// Kind of every power-up on the arena
+	PowerUps map[Point]int
+	// Index of the car which dropped the bomb
+	Bombs        map[Point]int
+	FrameBuffer  Symbols
+	Message      string
+	MessageUntil time.Time
+	BotLevel     string
+	Teams        int
+	FriendlyFire bool
+	ModeName     string
+	Mode         GameMode
+	// Name of the winner when the round is over, empty for a draw
+	Winner string
+	// Walls inside the arena, like the ones of race tracks
+	Walls   map[Point]bool
+	NoBombs bool
+	// Cars drawn under the real ones which don't crash, like the best lap in time trials
+	Ghosts         []Car
+	MaxPlayers     int
+	PowerUpWeights [powerUpKinds]int
 	sync.Mutex
 }
 
+// Rounds take the settings of the server at the moment they are created
+func newRound() *Round {
+	round := &Round{
+		Id:             rand.Int(),
+		FrameBuffer:    make([]Symbol, mapWidth*mapHeight),
+		PowerUps:       make(map[Point]int),
+		Bombs:          make(map[Point]int),
+		BotLevel:       defaultRoundBotLevel.Load().(string),
+		Teams:          conf.Teams,
+		FriendlyFire:   conf.FriendlyFire,
+		ModeName:       conf.Mode,
+		Mode:           gameModes[conf.Mode](),
+		MaxPlayers:     maxPlayersPerRound,
+		PowerUpWeights: conf.PowerUpWeights,
+	}
+	if soloModes[conf.Mode] {
+		round.MaxPlayers = 1
+	}
+	return round
+}
+
 func (round *Round) generateMap() {
 	// http://www.theasciicode.com.ar
 	for row := 0; row < mapHeight; row++ {
@@ -36,6 +77,21 @@ func (round *Round) generateMap() {
 			round.FrameBuffer[row*mapWidth+column] = Symbol{0, char}
 		}
 	}
+	for w := range round.Walls {
+		round.FrameBuffer[w.Y*mapWidth+w.X] = Symbol{RESET, []byte(wall)}
+	}
+}
+
+// Checks if the rectangle covers any wall inside the arena
+func (round *Round) hitsWalls(r *Rectangle) bool {
+	for y := r.Points[LEFTUP].Y; y <= r.Points[RIGHTDOWN].Y; y++ {
+		for x := r.Points[LEFTUP].X; x <= r.Points[RIGHTDOWN].X; x++ {
+			if round.Walls[Point{x, y}] {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (round *Round) generateBot() Player {
@@ -49,36 +105,23 @@ func (round *Round) generateBot() Player {
 	}
 }
 
-func (round *Round) getPlayersExcept(exceptions []Player) []*Player {
-	var rest []*Player
+func (round *Round) findPlayer(name string) *Player {
 	for i := range round.Players {
-		exception := false
-		for k := range exceptions {
-			if round.Players[i].Name == exceptions[k].Name {
-				exception = true
-			}
-		}
-		if !exception {
-			rest = append(rest, &round.Players[i])
+		if round.Players[i].Name == name {
+			return &round.Players[i]
 		}
 	}
-	return rest
+	return nil
 }
 
-func (round *Round) getRandomAliveNonBotPlayerId() int {
-	var alivePlayer []int
-
+// Seat of the player in the round, -1 if the player isn't there
+func (round *Round) indexOf(player *Player) int {
 	for i := range round.Players {
-		if round.Players[i].Health > 0 && !round.Players[i].Bot {
-			alivePlayer = append(alivePlayer, i)
+		if &round.Players[i] == player {
+			return i
 		}
 	}
-	if len(alivePlayer) > 0 {
-		return alivePlayer[rand.Intn(len(alivePlayer))]
-	} else {
-		return -1
-	}
-
+	return -1
 }
 
 func (round *Round) gameLogic() {
@@ -86,7 +129,7 @@ func (round *Round) gameLogic() {
 		if round.Players[i].Bot {
 			go round.Players[i].moveBot(round)
 		} else {
-			go round.Players[i].readDirection(round)
+			round.Players[i].play(round)
 		}
 		go round.Players[i].checkPosition(round)
 		go round.Players[i].checkSpeed(round)
@@ -95,83 +138,139 @@ func (round *Round) gameLogic() {
 	}
 }
 
+// The round is over when the mode has a winner, the time is out or no humans are left
 func (round *Round) checkGameOver(activeFrameBuffer Symbols) {
-	humans := 0
-	deadHumans := 0
-	deadPlayers := 0
-	winnersName := ""
-
+	activeHumans := 0
 	for _, p := range round.Players {
-		if p.Health <= 0 {
-			deadPlayers++
-		}
-
-		if !p.Bot {
-			humans++
-			if p.Health <= 0 {
-				deadHumans++
-			} else {
-				winnersName = p.Name
-			}
+		// Humans on autopilot don't keep the round running unless they may reconnect or come back from AFK
+		if !p.Bot && (!p.AutoPilot || p.waitingReconnect() || (p.Afk && !p.afkGraceOver())) && p.Health > 0 {
+			activeHumans++
 		}
 	}
 
+	winnersName := round.Mode.Winner(round)
 	secondsLeft := round.LastStateChange.Unix() + maxRoundRunningTimeSec - time.Now().Unix()
-	if humans == deadHumans || maxPlayersPerRound-deadPlayers == 1 || secondsLeft <= 0 {
-		round.State = FINISHED
-		fmt.Println(round.Id, "Round has changed to the state FINISHED")
-		if maxPlayersPerRound-deadPlayers == 1 {
+	if activeHumans == 0 || winnersName != "" || secondsLeft <= 0 {
+		round.Winner = winnersName
+		round.setState(FINISHED)
+		if winnersName != "" {
 			winnerStr := "THE WINNER IS " + winnersName + "!!!"
-			for i, char := range []byte(winnerStr) {
-				activeFrameBuffer[mapWidth*(mapHeight/2-2)+mapWidth/2-len(winnerStr)/2+i] = Symbol{GREEN, []byte{char}}
-			}
-			round.writeToAllPlayers(activeFrameBuffer.symbolsToByte(), false)
+			putText(activeFrameBuffer, mapWidth*(mapHeight/2-2)+mapWidth/2-textWidth(winnerStr)/2, winnerStr, GREEN)
+			// External bots get the winner with the over event
+			round.writeFrame(activeFrameBuffer.symbolsToByte())
 			time.Sleep(5 * time.Second)
 		}
 	}
 }
 
 func (round *Round) over() {
-	round.writeToAllPlayers([]byte("Time is out\n"), false)
+	registry.remove(round.Id)
 	for _, player := range round.Players {
 		if player.Bot {
 			player.Health = 0
+			botsCount.Dec()
 			continue
 		}
-		player.Conn.Close()
+		// Written before the disconnect, the connection of a player waiting for the reconnect is gone
+		if player.External {
+			sendEvent(player.Conn, botEvent{Type: "over", Winner: round.Winner})
+		} else if !player.waitingReconnect() {
+			player.Conn.Write([]byte("Time is out\n"))
+		}
+		disconnect(player.Conn, reasonRoundOver)
+	}
+	roundDuration.Observe(time.Since(round.LastStateChange).Seconds())
+	roundsByState.WithLabelValues(stateNames[round.State]).Dec()
+}
+
+func (round *Round) setState(state int) {
+	if round.State == state {
+		return
+	}
+	if round.State != 0 {
+		roundsByState.WithLabelValues(stateNames[round.State]).Dec()
+	}
+	round.logger().Info("Round has changed the state", "event", eventStateChange, "from", stateNames[round.State], "to", stateNames[state])
+	round.State = state
+	roundsByState.WithLabelValues(stateNames[state]).Inc()
+}
+
+func (round *Round) finish() error {
+	if round.State != STARTING && round.State != RUNNING {
+		return errors.New("Round is not running")
+	}
+	round.setState(FINISHED)
+	return nil
+}
+
+/*
+Running rounds show the message on top of the map for a few seconds,
+the rest get it as a plain text like the waiting countdown
+*/
+func (round *Round) broadcast(message string) {
+	if round.State == STARTING || round.State == RUNNING {
+		round.MessageUntil = time.Now().Add(broadcastDuration)
+		round.Message = message
+		return
+	}
+	round.writeToAllPlayers([]byte(message+"\n"), true)
+}
+
+func (round *Round) humans() int {
+	humans := 0
+	for _, p := range round.Players {
+		if !p.Bot {
+			humans++
+		}
 	}
+	return humans
 }
 
 func (round *Round) writeToAllPlayers(message []byte, clean bool) {
 	for i := range round.Players {
-		if round.Players[i].Bot {
+		if round.Players[i].Bot || round.Players[i].waitingReconnect() {
+			continue
+		}
+		if round.Players[i].External {
+			go round.Players[i].sendEvent(round, botEvent{Type: "message", Text: strings.TrimSpace(string(message))})
 			continue
 		}
 
-		go round.Players[i].writeToThePlayer(message, clean)
+		go round.Players[i].writeToThePlayer(round, message, clean)
 	}
 }
 
-func (round *Round) applyNames(lineBetweenPlayersInBar int) {
-	for line, player := range round.Players {
-		for i, char := range []byte(player.Name) {
-			round.FrameBuffer[(line*lineBetweenPlayersInBar+1)*mapWidth+(mapWidth-nameTableWidth+1)+i] = Symbol{player.Color, []byte{char}}
+// Frames are followed by the personal resume token of every player
+func (round *Round) writeFrame(frame []byte) {
+	for i := range round.Players {
+		if round.Players[i].Bot || round.Players[i].waitingReconnect() {
+			continue
 		}
-		round.FrameBuffer[(line*lineBetweenPlayersInBar+1)*mapWidth+(mapWidth-nameTableWidth+1)+len(player.Name)] = Symbol{RESET, []byte{':'}}
+		if round.Players[i].External {
+			go round.Players[i].sendEvent(round, round.botState(&round.Players[i]))
+			continue
+		}
+
+		message := append(frame[:len(frame):len(frame)], round.Players[i].reconnectFooter()...)
+		go round.Players[i].writeToThePlayer(round, message, false)
 	}
 }
 
-func (round *Round) applyBonus(activeFrameBuffer []Symbol) {
-	if round.State == STARTING {
-		return
+func (round *Round) applyNames(activeFrameBuffer []Symbol, lineBetweenPlayersInBar int) {
+	for line, player := range round.Players {
+		position := (line*lineBetweenPlayersInBar+1)*mapWidth + (mapWidth - nameTableWidth + 1)
+		nameWidth := putText(activeFrameBuffer, position, player.displayName(line), player.Color)
+		activeFrameBuffer[position+nameWidth] = Symbol{RESET, []byte{':'}}
 	}
-	if round.Bonus.X == -1 && round.Bonus.Y == -1 {
-		if rand.Int()%lowFactor == 0 {
-			round.Bonus = Point{rand.Intn(mapWidth-nameTableWidth-2) + 1, rand.Intn(mapHeight-2) + 1}
-		}
-	} else {
-		activeFrameBuffer[round.Bonus.Y*mapWidth+round.Bonus.X] = Symbol{RED, []byte(bonus)}
+}
+
+func (round *Round) applyMessage(activeFrameBuffer []Symbol) {
+	if round.Message == "" || time.Now().After(round.MessageUntil) {
+		return
 	}
+
+	message := truncateText(round.Message, mapWidth-nameTableWidth-2)
+	putText(activeFrameBuffer, mapWidth*(mapHeight/2+2)+(mapWidth-nameTableWidth)/2-textWidth(message)/2, message, BOLD)
 }
 
 func (round *Round) applyBombs(activeFrameBuffer []Symbol, lineBetweenPlayersInBar int) {
@@ -186,6 +285,24 @@ func (round *Round) applyBombs(activeFrameBuffer []Symbol, lineBetweenPlayersInB
 	round.Unlock()
 }
 
+/*
+The sidebar has a block of lines for every player and the footer at the bottom:
+the status of the mode and a line for every team. Blocks share the rows above the footer
+*/
+func (round *Round) footerRows() int {
+	return round.Teams + 1
+}
+
+func (round *Round) sidebarBlock() int {
+	return (mapHeight - 2 - round.footerRows()) / len(round.Players)
+}
+
+// Draws the text right aligned on the line of the player's block in the sidebar
+func (round *Round) applySidebarLine(activeFrameBuffer []Symbol, num, line int, text string, color int) {
+	lineBetweenPlayersInBar := round.sidebarBlock()
+	putText(activeFrameBuffer, ((num*lineBetweenPlayersInBar+line)+1)*mapWidth+(mapWidth-3)-textWidth(text), text, color)
+}
+
 func (round *Round) applyUserData(activeFrameBuffer []Symbol, lineBetweenPlayersInBar int) {
 	for num, player := range round.Players {
 		// Apply health
@@ -194,6 +311,9 @@ func (round *Round) applyUserData(activeFrameBuffer []Symbol, lineBetweenPlayers
 			// +1 because health is next line after the name
 			activeFrameBuffer[((num*lineBetweenPlayersInBar+1)+1)*mapWidth+(mapWidth-3)-len(health)+i] = Symbol{player.Color, []byte{char}}
 		}
+		if mode, ok := round.Mode.(scoreMode); ok {
+			putText(activeFrameBuffer, ((num*lineBetweenPlayersInBar+1)+1)*mapWidth+(mapWidth-nameTableWidth+1), mode.Score(round, num), player.Color)
+		}
 
 		// Apply the amount of bombs to the bar
 		bombs := []byte(fmt.Sprintf("Bombs: %4d", round.Players[num].Bombs))
@@ -201,6 +321,25 @@ func (round *Round) applyUserData(activeFrameBuffer []Symbol, lineBetweenPlayers
 			// +2 because "bombs" is next line after the name
 			activeFrameBuffer[((num*lineBetweenPlayersInBar+2)+1)*mapWidth+(mapWidth-3)-len(bombs)+i] = Symbol{player.Color, []byte{char}}
 		}
+
+		// Effects of power-ups go left from the bombs
+		putText(activeFrameBuffer, ((num*lineBetweenPlayersInBar+2)+1)*mapWidth+(mapWidth-nameTableWidth+1), truncateText(player.effectsStatus(), nameTableWidth-len(bombs)-5), player.Color)
+
+		// Apply AFK warning or autopilot
+		status := ""
+		if player.waitingReconnect() {
+			status = "Reconnecting..."
+		} else if left := player.afkSecondsLeft(); left >= 0 {
+			status = fmt.Sprintf("AFK! Press a key: %2d", left)
+		} else if player.AutoPilot {
+			status = "Autopilot"
+		} else if player.respawning() {
+			status = fmt.Sprintf("Respawn in %d", int(player.RespawnAt.Sub(now()).Seconds())+1)
+		}
+		for i, char := range []byte(status) {
+			// +3 because status is next line after bombs
+			activeFrameBuffer[((num*lineBetweenPlayersInBar+3)+1)*mapWidth+(mapWidth-3)-len(status)+i] = Symbol{RED, []byte{char}}
+		}
 	}
 }
 
@@ -208,8 +347,7 @@ func (round *Round) applyGetReady(activeFrameBuffer []Symbol, getReadyCounter *i
 	if round.State == STARTING {
 		getReady := "GET READY!"
 		if *getReadyCounter == 0 {
-			fmt.Println(round.Id, "Round has changed to the state RUNNING")
-			round.State = RUNNING
+			round.setState(RUNNING)
 		} else if *getReadyCounter <= framesPerSecond*1 {
 			getReady += " 1"
 		} else if *getReadyCounter <= framesPerSecond*2 {
@@ -231,57 +369,77 @@ func (round *Round) applyCars(activeMap []Symbol) {
 		recover()
 	}()
 
+	// Ghosts are under the real cars
+	for i := range round.Ghosts {
+		applyCar(activeMap, &round.Ghosts[i], DIM, false)
+	}
 	for _, player := range round.Players {
-		charPosX, charPosY := 0, 0
-		for i := 0; i < len(cars[player.Car.Direction]); i++ {
-			var chars []byte
-			if cars[player.Car.Direction][i] == byte('\n') {
-				charPosY++
-				charPosX = 0
-				continue
-			} else if cars[player.Car.Direction][i] == 226 {
-				/*
-				 This means extended ASCII is used. After 226 2 bytes must follow
-				*/
-				chars = []byte{cars[player.Car.Direction][i], cars[player.Car.Direction][i+1], cars[player.Car.Direction][i+2]}
-				i += 2
-			} else if cars[player.Car.Direction][i] == 194 {
-				/*
-				 This means extended ASCII is used. After 194 1 bytes must follow
-				*/
-				chars = []byte{cars[player.Car.Direction][i], cars[player.Car.Direction][i+1]}
-				i++
-			} else if player.Health <= 0 && cars[player.Car.Direction][i] == 'o' {
-				chars = []byte{'x'}
-			} else {
-				chars = []byte{cars[player.Car.Direction][i]}
-			}
-			activeMap[(player.Car.Borders.Points[LEFTUP].Y+charPosY)*mapWidth+player.Car.Borders.Points[LEFTUP].X+charPosX] = Symbol{player.Color, chars}
-			charPosX++
+		color := player.Color
+		if player.respawning() {
+			continue
+		} else if player.invulnerable() || player.hasEffect(GHOST) {
+			color = DIM
+		}
+		applyCar(activeMap, &player.Car, color, player.Health <= 0)
+	}
+}
+
+func applyCar(activeMap []Symbol, car *Car, color int, wreck bool) {
+	charPosX, charPosY := 0, 0
+	for i := 0; i < len(cars[car.Direction]); i++ {
+		var chars []byte
+		if cars[car.Direction][i] == byte('\n') {
+			charPosY++
+			charPosX = 0
+			continue
+		} else if cars[car.Direction][i] == 226 {
+			/*
+			 This means extended ASCII is used. After 226 2 bytes must follow
+			*/
+			chars = []byte{cars[car.Direction][i], cars[car.Direction][i+1], cars[car.Direction][i+2]}
+			i += 2
+		} else if cars[car.Direction][i] == 194 {
+			/*
+			 This means extended ASCII is used. After 194 1 bytes must follow
+			*/
+			chars = []byte{cars[car.Direction][i], cars[car.Direction][i+1]}
+			i++
+		} else if wreck && cars[car.Direction][i] == 'o' {
+			chars = []byte{'x'}
+		} else {
+			chars = []byte{cars[car.Direction][i]}
 		}
+		activeMap[(car.Borders.Points[LEFTUP].Y+charPosY)*mapWidth+car.Borders.Points[LEFTUP].X+charPosX] = Symbol{color, chars}
+		charPosX++
 	}
 }
 
 // We start round only if more than 0 player is presented
 func (round *Round) start() {
-	lineBetweenPlayersInBar := mapHeight / len(round.Players)
+	lineBetweenPlayersInBar := round.sidebarBlock()
 	getReadyCounter := getReadyPause / framesPerSecond
 
+	round.Mode.Start(round)
 	round.gameLogic()
 	round.generateMap()
-	round.applyNames(lineBetweenPlayersInBar)
 
 	for {
+		frameStart := time.Now()
 		activeFrameBuffer := make(Symbols, len(round.FrameBuffer))
 		copy(activeFrameBuffer, round.FrameBuffer)
 
+		round.applyNames(activeFrameBuffer, lineBetweenPlayersInBar)
 		round.applyUserData(activeFrameBuffer, lineBetweenPlayersInBar)
-		round.applyBonus(activeFrameBuffer)
+		round.applyPowerUps(activeFrameBuffer)
 		round.applyBombs(activeFrameBuffer, lineBetweenPlayersInBar)
 		round.applyCars(activeFrameBuffer)
+		round.Mode.Apply(round, activeFrameBuffer)
 		round.applyGetReady(activeFrameBuffer, &getReadyCounter)
+		round.applyMessage(activeFrameBuffer)
 
-		round.writeToAllPlayers(activeFrameBuffer.symbolsToByte(), false)
+		frame := activeFrameBuffer.symbolsToByte()
+		observeFrame(frameStart)
+		round.writeFrame(frame)
 
 		round.checkGameOver(activeFrameBuffer)
 		if round.State == FINISHED {
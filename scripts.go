@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+/*
+Scripted bots are Starlark files in the bots directory of the artifacts.
+The name of the file is the bot level. The script defines
+
+	def decide(state, memory):
+	    return action(direction = "left", bomb = True)
+
+state has the same fields as the state of the external bot protocol, memory
+is a dict kept between the decisions of the car. None keeps the direction.
+INTERVAL_MS sets the time between decisions
+*/
+
+const (
+	scriptsDir            = "bots"
+	scriptExtension       = ".star"
+	defaultScriptInterval = 300 * time.Millisecond
+	minScriptInterval     = 50 * time.Millisecond
+	// Stops endless loops in scripts
+	scriptMaxSteps = 1000000
+)
+
+// map[string]*botScript, replaced as a whole on reload
+var botScripts atomic.Value
+
+type botScript struct {
+	name     string
+	decide   starlark.Value
+	interval time.Duration
+}
+
+var scriptBuiltins = starlark.StringDict{
+	"action": starlark.NewBuiltin("action", scriptAction),
+	"random": starlark.NewBuiltin("random", scriptRandom),
+}
+
+// action(direction = None, bomb = False)
+func scriptAction(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var direction starlark.Value = starlark.None
+	bomb := false
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "direction?", &direction, "bomb?", &bomb); err != nil {
+		return nil, err
+	}
+	return starlarkstruct.FromStringDict(starlark.String("action"), starlark.StringDict{
+		"direction": direction,
+		"bomb":      starlark.Bool(bomb),
+	}), nil
+}
+
+// random(n) returns a number from 0 to n-1
+func scriptRandom(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var n int
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &n); err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("%s: n must be positive", b.Name())
+	}
+	return starlark.MakeInt(rand.Intn(n)), nil
+}
+
+func scriptPrint(name string) func(*starlark.Thread, string) {
+	return func(_ *starlark.Thread, msg string) {
+		conf.Log.Debug("Bot script", "script", name, "msg", msg)
+	}
+}
+
+/*
+Loads all scripts of the directory. Broken scripts are skipped and reported
+in the error, so one script doesn't stop the others
+*/
+func loadBotScripts(artifacts string) (map[string]*botScript, error) {
+	scripts := make(map[string]*botScript)
+	paths, _ := filepath.Glob(filepath.Join(artifacts, scriptsDir, "*"+scriptExtension))
+
+	var errs []error
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), scriptExtension)
+		if _, ok := botLevels[name]; ok {
+			errs = append(errs, fmt.Errorf("Script %s has the name of a built-in bot level", path))
+			continue
+		}
+		script, err := loadBotScript(name, path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		scripts[name] = script
+	}
+	return scripts, errors.Join(errs...)
+}
+
+func loadBotScript(name, path string) (*botScript, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	thread := &starlark.Thread{Name: name, Print: scriptPrint(name)}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+	globals, err := starlark.ExecFile(thread, path, src, scriptBuiltins)
+	if err != nil {
+		return nil, err
+	}
+	// Cars of all rounds call the same functions concurrently
+	globals.Freeze()
+
+	decide, ok := globals["decide"].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("Script %s has no decide function", path)
+	}
+	script := &botScript{name: name, decide: decide, interval: defaultScriptInterval}
+	if v, ok := globals["INTERVAL_MS"]; ok {
+		ms, err := starlark.AsInt32(v)
+		if err != nil {
+			return nil, fmt.Errorf("Script %s: INTERVAL_MS: %w", path, err)
+		}
+		script.interval = max(time.Duration(ms)*time.Millisecond, minScriptInterval)
+	}
+	return script, nil
+}
+
+func loadedBotScripts() map[string]*botScript {
+	scripts, _ := botScripts.Load().(map[string]*botScript)
+	return scripts
+}
+
+func scriptNames() []string {
+	var names []string
+	for name := range loadedBotScripts() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// scriptBrain runs the script for one car
+type scriptBrain struct {
+	script *botScript
+	memory *starlark.Dict
+	failed bool
+}
+
+func (b *scriptBrain) Interval() time.Duration {
+	return b.script.interval
+}
+
+func (b *scriptBrain) Decide(s *RoundSnapshot) BotAction {
+	action := BotAction{Direction: -1}
+	thread := &starlark.Thread{Name: b.script.name, Print: scriptPrint(b.script.name)}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+
+	result, err := starlark.Call(thread, b.script.decide, starlark.Tuple{scriptState(newBotState(s)), b.memory}, nil)
+	if err == nil {
+		action, err = scriptResult(result)
+	}
+	if err != nil {
+		// Once per car, scripts fail the same way every time
+		if !b.failed {
+			conf.Log.Warn("Bot script failed", "script", b.script.name, "err", err)
+			b.failed = true
+		}
+		return BotAction{Direction: -1}
+	}
+	return action
+}
+
+func scriptResult(result starlark.Value) (BotAction, error) {
+	action := BotAction{Direction: -1}
+	if result == starlark.None {
+		return action, nil
+	}
+	s, ok := result.(*starlarkstruct.Struct)
+	if !ok {
+		return action, fmt.Errorf("decide returned %s instead of action()", result.Type())
+	}
+
+	direction, _ := s.Attr("direction")
+	if name, ok := starlark.AsString(direction); ok {
+		d, err := parseDirection(name)
+		if err != nil {
+			return action, err
+		}
+		action.Direction = d
+	}
+	bomb, _ := s.Attr("bomb")
+	action.DropBomb = bomb == starlark.True
+	return action, nil
+}
+
+func scriptRect(r botRect) starlark.StringDict {
+	return starlark.StringDict{
+		"x":      starlark.MakeInt(r.X),
+		"y":      starlark.MakeInt(r.Y),
+		"width":  starlark.MakeInt(r.Width),
+		"height": starlark.MakeInt(r.Height),
+	}
+}
+
+func scriptPoint(p botPoint) starlark.Value {
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"x": starlark.MakeInt(p.X),
+		"y": starlark.MakeInt(p.Y),
+	})
+}
+
+func scriptState(state botState) starlark.Value {
+	var cars, bombs, walls, powerUps []starlark.Value
+	for _, c := range state.Cars {
+		car := scriptRect(c.botRect)
+		car["name"] = starlark.String(c.Name)
+		car["bot"] = starlark.Bool(c.Bot)
+		car["team"] = starlark.MakeInt(c.Team)
+		car["health"] = starlark.MakeInt64(c.Health)
+		car["bombs"] = starlark.MakeInt(c.Bombs)
+		car["direction"] = starlark.String(c.Direction)
+		car["speed"] = starlark.MakeInt64(c.Speed)
+		cars = append(cars, starlarkstruct.FromStringDict(starlarkstruct.Default, car))
+	}
+	for _, b := range state.Bombs {
+		bombs = append(bombs, scriptPoint(b))
+	}
+	for _, w := range state.Walls {
+		walls = append(walls, scriptPoint(w))
+	}
+	for _, p := range state.PowerUps {
+		powerUps = append(powerUps, starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+			"x":    starlark.MakeInt(p.X),
+			"y":    starlark.MakeInt(p.Y),
+			"type": starlark.String(p.Type),
+		}))
+	}
+	var bonus, goal starlark.Value = starlark.None, starlark.None
+	if state.Bonus != nil {
+		bonus = scriptPoint(*state.Bonus)
+	}
+	if state.Goal != nil {
+		goal = scriptPoint(*state.Goal)
+	}
+
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"state":    starlark.String(state.State),
+		"me":       starlark.MakeInt(state.Me),
+		"cars":     starlark.NewList(cars),
+		"bombs":    starlark.NewList(bombs),
+		"bonus":    bonus,
+		"powerups": starlark.NewList(powerUps),
+		"walls":    starlark.NewList(walls),
+		"goal":     goal,
+		"arena":    starlarkstruct.FromStringDict(starlarkstruct.Default, scriptRect(state.Arena)),
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import "time"
+
+/*
+Sudden death: a deathmatch where after a while the safe zone contracts
+step by step from the borders of the arena. Cars outside of the zone lose
+health every second, so cautious players can't circle each other forever
+*/
+
+const zoneGlyph = "\xE2\x96\x91"
+
+// Health lost every second outside of the zone
+const zoneDamage = 10
+
+// The zone stops shrinking when it is about this small
+const minZoneWidth = 30
+const minZoneHeight = 10
+
+type shrinkMode struct {
+	deathmatchMode
+	after    time.Duration
+	interval time.Duration
+
+	started    time.Time
+	steps      int
+	zone       Rectangle
+	lastDamage time.Time
+}
+
+func arenaRectangle() Rectangle {
+	return newRectangle(1, 1, mapWidth-nameTableWidth-1, mapHeight-2)
+}
+
+func (m *shrinkMode) Start(round *Round) {
+	m.zone = arenaRectangle()
+}
+
+func (m *shrinkMode) Apply(round *Round, activeFrameBuffer []Symbol) {
+	m.deathmatchMode.Apply(round, activeFrameBuffer)
+	if round.State == RUNNING {
+		if m.started.IsZero() {
+			m.started = now()
+		}
+		m.shrink(round)
+		m.hurt(round)
+	}
+
+	if m.steps > 0 {
+		// The edge goes around the zone
+		edge := newRectangle(m.zone.Points[LEFTUP].X-1, m.zone.Points[LEFTUP].Y-1,
+			m.zone.Points[RIGHTDOWN].X-m.zone.Points[LEFTUP].X+3, m.zone.Points[RIGHTDOWN].Y-m.zone.Points[LEFTUP].Y+3)
+		drawWhereEmpty(activeFrameBuffer, edge.edge(), Symbol{RED, []byte(zoneGlyph)})
+	}
+
+	status := "Sudden death"
+	if left := m.after - now().Sub(m.started); m.started.IsZero() || left > 0 {
+		status = "Sudden death in " + formatRaceTime(min(left, m.after))
+	}
+	round.applyModeStatus(activeFrameBuffer, status, BOLD)
+}
+
+// Moves the edges of the zone by a step every interval, vertical steps are 3x smaller
+func (m *shrinkMode) shrink(round *Round) {
+	elapsed := now().Sub(m.started)
+	if elapsed < m.after {
+		return
+	}
+
+	arena := arenaRectangle()
+	width, height := arena.Points[RIGHTDOWN].X-arena.Points[LEFTUP].X+1, arena.Points[RIGHTDOWN].Y-arena.Points[LEFTUP].Y+1
+	maxSteps := min((width-minZoneWidth)/(2*verticalCost), (height-minZoneHeight)/2)
+	steps := min(int((elapsed-m.after)/m.interval)+1, maxSteps)
+	if steps == m.steps {
+		return
+	}
+	if m.steps == 0 {
+		round.broadcast("SUDDEN DEATH! The arena is shrinking!")
+	}
+	m.steps = steps
+	m.zone = newRectangle(arena.Points[LEFTUP].X+steps*verticalCost, arena.Points[LEFTUP].Y+steps,
+		width-2*steps*verticalCost, height-2*steps)
+}
+
+func (m *shrinkMode) hurt(round *Round) {
+	if m.steps == 0 || now().Sub(m.lastDamage) < time.Second {
+		return
+	}
+	m.lastDamage = now()
+	for num := range round.Players {
+		p := &round.Players[num]
+		if p.Health > 0 && !m.inside(p) {
+			p.Health -= zoneDamage
+			p.logger(round).Debug("Player is hurt outside of the zone", "health", p.Health)
+		}
+	}
+}
+
+func (m *shrinkMode) inside(p *Player) bool {
+	return m.zone.containsRectangle(&p.Car.Borders)
+}
+
+// Once the zone shrinks its edge is the wall
+func (m *shrinkMode) Zone(round *Round) (Rectangle, bool) {
+	return m.zone, m.steps > 0
+}
+
+// Bots outside of the zone drive back to its middle
+func (m *shrinkMode) Goal(round *Round, num int) (Point, bool) {
+	if m.steps == 0 || m.inside(&round.Players[num]) {
+		return Point{}, false
+	}
+	return m.zone.center(), true
+}
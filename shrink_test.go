@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestShrinkZoneWall(t *testing.T) {
+	zone := newRectangle(20, 5, 40, 20)
+	tests := []struct {
+		name  string
+		steps int
+		at    Point
+		hit   bool
+	}{
+		{"inside", 1, Point{30, 10}, false},
+		{"leaving", 1, Point{59 - horizontalCarWidth + 1, 10}, true},
+		{"not shrinking yet", 0, Point{59 - horizontalCarWidth + 1, 10}, false},
+		{"left outside", 1, Point{59 - horizontalCarWidth + 2, 10}, false},
+		{"driving in", 1, Point{20 - horizontalCarWidth, 10}, false},
+	}
+	for _, test := range tests {
+		round := testRound(0, testCar{100, 0})
+		round.Mode = &shrinkMode{zone: zone, steps: test.steps}
+		driveTo(round, 0, test.at)
+		player := &round.Players[0]
+		player.Car.Direction, player.Car.Speed = RIGHT, 1
+		player.Car.recalculateBorders(false)
+
+		if hit := player.checkHitWall(round); hit != test.hit {
+			t.Errorf("%s: hit %t, want %t", test.name, hit, test.hit)
+		}
+		wantHealth := int64(100)
+		if test.hit {
+			wantHealth -= DAMAGE_FRONT
+		}
+		if player.Health != wantHealth {
+			t.Errorf("%s: health %d, want %d", test.name, player.Health, wantHealth)
+		}
+	}
+}
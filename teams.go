@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+Team deathmatch splits the seats of the round between Teams: seat N plays for
+team N % Teams. In free-for-all rounds (Teams is 0) every car is a team of its own,
+so the rest of the game doesn't need to know about the mode
+*/
+
+const maxTeams = 4
+
+var teamColors = [maxTeams]int{RED, BLUE, GREEN, YELLOW}
+var teamNames = [maxTeams]string{"Red", "Blue", "Green", "Yellow"}
+
+func validateTeams(teams int) error {
+	if teams != 0 && (teams < 2 || teams > maxTeams) {
+		return fmt.Errorf("Amount of teams must be 0 for free-for-all or between 2 and %d", maxTeams)
+	}
+	return nil
+}
+
+// Seats the player in the team, free-for-all players keep their own colors
+func (p *Player) initTeam(id, teams int) {
+	if teams == 0 {
+		p.Team = id
+		// Colors are sequential, so we can use first color RED and set the rest based on IDs
+		p.Color = RED + id
+		return
+	}
+	p.Team = id % teams
+	p.Color = teamColors[p.Team]
+}
+
+// Checks if the team may not hurt the player: only teammates without friendly fire
+func (round *Round) friendly(team int, player *Player) bool {
+	return round.Teams > 0 && !round.FriendlyFire && team == player.Team
+}
+
+// Name of the winner: the player in free-for-all, the team otherwise
+func (round *Round) sideName(num int) string {
+	if round.Teams > 0 {
+		return teamTitle(round.Players[num].Team)
+	}
+	return round.Players[num].displayName(num)
+}
+
+func teamTitle(team int) string {
+	return "THE " + strings.ToUpper(teamNames[team]) + " TEAM"
+}
+
+/*
+Name of the last team with living cars, empty while more teams are alive.
+A round with a single team is finished only by death or time
+*/
+func (round *Round) lastTeamStanding() string {
+	teams := make(map[int]bool)
+	aliveTeams := make(map[int]bool)
+	winnersName := ""
+
+	for num, p := range round.Players {
+		teams[p.Team] = true
+		if p.Health > 0 {
+			aliveTeams[p.Team] = true
+			winnersName = round.sideName(num)
+		}
+	}
+	if len(teams) > 1 && len(aliveTeams) == 1 {
+		return winnersName
+	}
+	return ""
+}
+
+// Living cars of the team and their total health
+func (round *Round) teamStatus(team int) (int, int64) {
+	alive, health := 0, int64(0)
+	for _, p := range round.Players {
+		if p.Team == team && p.Health > 0 {
+			alive++
+			health += p.Health
+		}
+	}
+	return alive, health
+}
+
+// Team score on its line of the sidebar footer, under the status of the mode
+func (round *Round) applyTeamScore(activeFrameBuffer []Symbol, team int, score string) {
+	row := mapHeight - 1 - round.Teams + team
+	putText(activeFrameBuffer, row*mapWidth+(mapWidth-nameTableWidth+1), teamNames[team]+": "+score, teamColors[team])
+}
@@ -40,3 +40,39 @@ func readTelnet(conn net.Conn) error {
 		}
 	}
 }
+
+/*
+Removes telnet commands from the input: IAC with the command,
+the option of WILL/WONT/DO/DONT and the whole subnegotiation
+*/
+func stripTelnetCommands(input []byte) []byte {
+	var output []byte
+	for i := 0; i < len(input); i++ {
+		if input[i] != 255 {
+			output = append(output, input[i])
+			continue
+		}
+		if i+1 >= len(input) {
+			break
+		}
+		switch command := input[i+1]; {
+		case command == 255:
+			// Escaped 255 is a data byte
+			output = append(output, 255)
+			i++
+		case command >= 251 && command <= 254:
+			// IAC WILL/WONT/DO/DONT option
+			i += 2
+		case command == 250:
+			// IAC SB ... IAC SE
+			i += 2
+			for i+1 < len(input) && !(input[i] == 255 && input[i+1] == 240) {
+				i++
+			}
+			i++
+		default:
+			i++
+		}
+	}
+	return output
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+var (
+	errEmptyName       = errors.New("Name can't be empty")
+	errTooLongName     = fmt.Errorf("Name is too long, the maximum is %d characters", maxNameLength)
+	errUnprintableName = errors.New("Name may contain only printable characters")
+)
+
+/*
+Amount of terminal columns the rune takes. Wide and fullwidth runes
+(CJK, most emoji) take 2 columns, the rest take 1
+*/
+func runeWidth(r rune) int {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+func textWidth(text string) int {
+	w := 0
+	for _, r := range text {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+/*
+Names are drawn on screens of all players, so they must not contain anything
+a terminal interprets: escape sequences, control characters or broken UTF-8.
+Combining marks and format characters are forbidden too, because they don't
+take a column of their own and break the sidebar
+*/
+func validateName(name string) error {
+	if name == "" {
+		return errEmptyName
+	}
+	if !utf8.ValidString(name) {
+		return errUnprintableName
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) || unicode.Is(unicode.M, r) {
+			return errUnprintableName
+		}
+	}
+	if textWidth(name) > maxNameLength {
+		return errTooLongName
+	}
+	return nil
+}
+
+/*
+Draws the text to the frame buffer starting from the position and returns
+the amount of columns it took. The column after a wide rune is left empty,
+because the terminal draws the wide rune over it. Text past the end of the
+frame buffer is cut
+*/
+func putText(frameBuffer []Symbol, position int, text string, color int) int {
+	column := 0
+	for _, r := range text {
+		if position+column < 0 || position+column+runeWidth(r) > len(frameBuffer) {
+			break
+		}
+		frameBuffer[position+column] = Symbol{color, []byte(string(r))}
+		if runeWidth(r) == 2 {
+			frameBuffer[position+column+1] = Symbol{color, []byte{}}
+		}
+		column += runeWidth(r)
+	}
+	return column
+}
+
+// Cuts the text to fit into the amount of columns
+func truncateText(text string, columns int) string {
+	var b strings.Builder
+	w := 0
+	for _, r := range text {
+		if w+runeWidth(r) > columns {
+			break
+		}
+		w += runeWidth(r)
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"Bob", nil},
+		{"Jürgen", nil},
+		{"東京", nil},
+		{"🚗 racer", nil},
+		{strings.Repeat("a", maxNameLength), nil},
+		{strings.Repeat("東", maxNameLength/2), nil},
+		{"", errEmptyName},
+		{strings.Repeat("a", maxNameLength+1), errTooLongName},
+		{strings.Repeat("東", maxNameLength/2+1), errTooLongName},
+		{"Bob\x1b[31m", errUnprintableName},
+		{"Bob\x07", errUnprintableName},
+		{"Bob\tBob", errUnprintableName},
+		// Combining mark, zero width space and right-to-left override
+		{"Bo\u0308b", errUnprintableName},
+		{"Bob\u200b", errUnprintableName},
+		{"Bob\u202e", errUnprintableName},
+		// Broken UTF-8
+		{"Bob\xff", errUnprintableName},
+	}
+	for _, test := range tests {
+		if err := validateName(test.name); err != test.err {
+			t.Errorf("validateName(%q) = %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+	}{
+		{"", 0},
+		{"Bob", 3},
+		{"Jürgen", 6},
+		{"東京", 4},
+		{"ｂｏｂ", 6},
+		{"ｶﾀｶﾅ", 4},
+		{"🚗", 2},
+		{"a東b", 4},
+	}
+	for _, test := range tests {
+		if width := textWidth(test.text); width != test.width {
+			t.Errorf("textWidth(%q) = %d, want %d", test.text, width, test.width)
+		}
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		text      string
+		columns   int
+		truncated string
+	}{
+		{"Bob", 5, "Bob"},
+		{"Bob", 3, "Bob"},
+		{"Bobby", 3, "Bob"},
+		{"Bob", 0, ""},
+		{"東京都", 4, "東京"},
+		// The wide rune doesn't fit into the last column
+		{"東京都", 5, "東京"},
+		{"a東", 2, "a"},
+	}
+	for _, test := range tests {
+		if truncated := truncateText(test.text, test.columns); truncated != test.truncated {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", test.text, test.columns, truncated, test.truncated)
+		}
+	}
+}
+
+func TestPutText(t *testing.T) {
+	tests := []struct {
+		position int
+		text     string
+		columns  int
+		// Frame buffer of 8 columns, the dots are left untouched
+		frame string
+	}{
+		{0, "Bob", 3, "Bob....."},
+		{5, "Bob", 3, ".....Bob"},
+		{1, "東京", 4, ".東京..."},
+		{0, "a東b", 4, "a東b...."},
+		{0, "", 0, "........"},
+		// Text past the end of the frame buffer is cut
+		{6, "Bob", 2, "......Bo"},
+		{7, "東", 0, "........"},
+		{-1, "Bob", 0, "........"},
+	}
+	for _, test := range tests {
+		frameBuffer := make([]Symbol, 8)
+		for i := range frameBuffer {
+			frameBuffer[i] = Symbol{0, []byte(".")}
+		}
+		columns := putText(frameBuffer, test.position, test.text, RED)
+
+		var frame strings.Builder
+		for _, s := range frameBuffer {
+			frame.Write(s.Char)
+		}
+		if columns != test.columns || frame.String() != test.frame {
+			t.Errorf("putText(%d, %q) = %d drawing %q, want %d drawing %q", test.position, test.text,
+				columns, frame.String(), test.columns, test.frame)
+		}
+	}
+}
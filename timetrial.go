@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+Time trial is a race of a single car without bots. The best lap of every
+player on every track is kept in the lap records and replayed as a ghost:
+the player's own best lap, or the record of the track if the player has none
+*/
+
+type ghostFrame struct {
+	At        time.Duration `json:"at"`
+	Borders   Rectangle     `json:"borders"`
+	Direction int           `json:"direction"`
+}
+
+type bestLap struct {
+	Time   time.Duration `json:"time"`
+	Frames []ghostFrame  `json:"frames"`
+}
+
+// lapRecords are stored as JSON like the ban list: track, then player
+type lapRecords struct {
+	sync.Mutex
+	path   string
+	Tracks map[string]map[string]*bestLap
+}
+
+func loadLapRecords(path string) (*lapRecords, error) {
+	records := &lapRecords{path: path, Tracks: make(map[string]map[string]*bestLap)}
+	if path == "" {
+		return records, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return records, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &records.Tracks); err != nil {
+		return nil, fmt.Errorf("Bad lap records %s: %w", path, err)
+	}
+	return records, nil
+}
+
+func (r *lapRecords) save() error {
+	if r.path == "" {
+		return nil
+	}
+	return writeFileAtomic(r.path, r.Tracks)
+}
+
+// Keeps the lap if it is the best of the player on the track
+func (r *lapRecords) add(track, name string, lap *bestLap) (bool, error) {
+	r.Lock()
+	defer r.Unlock()
+	if r.Tracks[track] == nil {
+		r.Tracks[track] = make(map[string]*bestLap)
+	}
+	if best, ok := r.Tracks[track][name]; ok && best.Time <= lap.Time {
+		return false, nil
+	}
+	r.Tracks[track][name] = lap
+	return true, r.save()
+}
+
+// Best lap of the player and the record of the track, nil if there are none
+func (r *lapRecords) best(track, name string) (*bestLap, string, *bestLap) {
+	r.Lock()
+	defer r.Unlock()
+	var holder string
+	var record *bestLap
+	for n, lap := range r.Tracks[track] {
+		if record == nil || lap.Time < record.Time {
+			holder, record = n, lap
+		}
+	}
+	return r.Tracks[track][name], holder, record
+}
+
+type timeTrialMode struct {
+	raceMode
+	records *lapRecords
+	ghost   *bestLap
+	// Frames of the current lap and the amount of laps already recorded
+	lap      []ghostFrame
+	recorded int
+}
+
+func (m *timeTrialMode) Start(round *Round) {
+	m.raceMode.Start(round)
+	m.ghost = m.ghostOf(round.Players[0].Name)
+}
+
+func (m *timeTrialMode) ghostOf(name string) *bestLap {
+	own, _, record := m.records.best(m.track.Name, name)
+	if own != nil {
+		return own
+	}
+	return record
+}
+
+func (m *timeTrialMode) Apply(round *Round, activeFrameBuffer []Symbol) {
+	m.raceMode.Apply(round, activeFrameBuffer)
+	p := &round.Players[0]
+	r := &m.racers[0]
+
+	if r.Laps > m.recorded {
+		m.recorded = r.Laps
+		lap := &bestLap{Time: r.LastLap, Frames: m.lap}
+		if improved, err := m.records.add(m.track.Name, p.Name, lap); err != nil {
+			p.logger(round).Error("Failed to save the lap records", "err", err)
+		} else if improved {
+			round.broadcast(fmt.Sprintf("New best lap %s!", formatRaceTime(lap.Time)))
+			m.ghost = lap
+		}
+		m.lap = nil
+	}
+
+	round.Ghosts = nil
+	if r.Started && r.Finished == 0 {
+		lapTime := now().Sub(r.LapStart)
+		m.lap = append(m.lap, ghostFrame{lapTime, p.Car.Borders, p.Car.Direction})
+		if m.ghost != nil {
+			// The last frame of the ghost at the time of the lap
+			frame := sort.Search(len(m.ghost.Frames), func(i int) bool { return m.ghost.Frames[i].At > lapTime }) - 1
+			if frame >= 0 && frame < len(m.ghost.Frames)-1 {
+				round.Ghosts = []Car{{Borders: m.ghost.Frames[frame].Borders, Direction: m.ghost.Frames[frame].Direction}}
+			}
+		}
+	}
+
+	own, holder, record := m.records.best(m.track.Name, p.Name)
+	if own != nil {
+		round.applySidebarLine(activeFrameBuffer, 0, 7, "Best "+formatRaceTime(own.Time), p.Color)
+	}
+	if record != nil {
+		round.applySidebarLine(activeFrameBuffer, 0, 8, truncateText("Record "+formatRaceTime(record.Time)+" "+holder, nameTableWidth-3), p.Color)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+/*
+Tournament plays rounds of bots without network and rendering. Time is
+simulated in ticks, so a round of minutes takes milliseconds. Every goroutine
+of the real round is a step here, called as often as the goroutine would run
+*/
+
+const tournamentTick = 10 * time.Millisecond
+
+// The period of checkSpeed, checkHealth, checkBomb and frames
+const tournamentFrame = 1 % framesPerSecond * 100 * time.Millisecond
+
+type brainStats struct {
+	Level    string
+	Rounds   int
+	Wins     int
+	Draws    int
+	Damage   int64
+	Survived time.Duration
+}
+
+type simulatedCar struct {
+	brain        BotBrain
+	level        string
+	nextMove     time.Duration
+	nextDecision time.Duration
+	health       int64
+	damage       int64
+	died         time.Duration
+}
+
+func tournament(args []string) int {
+	flags := flag.NewFlagSet("tournament", flag.ExitOnError)
+	rounds := flags.Int("rounds", 100, "Amount of rounds to play")
+	brains := flags.String("brains", "", "Comma separated bot levels to compare, all built-in levels and scripts if empty")
+	players := flags.Int("players", 2, fmt.Sprintf("Cars per round, 2-%d", maxPlayersPerRound))
+	maxTime := flags.Duration("max-time", maxRoundRunningTimeSec*time.Second, "Simulated time after which the round is a draw")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "Random seed, the same seed plays the same tournament")
+	acidPath := flags.String("a", defaultAcidPath, "Artifacts location with bot scripts")
+	powerUpWeights := flags.String("powerups", defaultPowerUpWeights, "Spawn weights of power-ups like heart=6,shield=2")
+	flags.Parse(args)
+
+	conf.Log = slog.New(slog.NewTextHandler(io.Discard, nil))
+	scripts, err := loadBotScripts(*acidPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	botScripts.Store(scripts)
+	if conf.PowerUpWeights, err = parsePowerUpWeights(*powerUpWeights); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	levels := botLevelNames()
+	if *brains != "" {
+		levels = strings.Split(*brains, ",")
+	}
+	for _, level := range levels {
+		if _, err := newBotBrain(level); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	if *players < 2 || *players > maxPlayersPerRound {
+		fmt.Fprintf(os.Stderr, "Cars per round must be between 2 and %d\n", maxPlayersPerRound)
+		return 1
+	}
+
+	rand.Seed(*seed)
+
+	stats := make(map[string]*brainStats)
+	for _, level := range levels {
+		stats[level] = &brainStats{Level: level}
+	}
+
+	matchups := combinations(levels, *players)
+	started := time.Now()
+	for i := 0; i < *rounds; i++ {
+		matchup := matchups[i%len(matchups)]
+		// Rotate the seats, so every level starts from every corner
+		shift := (i / len(matchups)) % len(matchup)
+		seats := append(append([]string{}, matchup[shift:]...), matchup[:shift]...)
+		playTournamentRound(seats, *maxTime, stats)
+	}
+
+	printTournament(os.Stdout, stats, *rounds, time.Since(started), *seed)
+	return 0
+}
+
+/*
+All matchups of the size. Levels don't repeat in a matchup unless there are
+less levels than cars
+*/
+func combinations(levels []string, size int) [][]string {
+	var result [][]string
+	var walk func(start int, current []string)
+	walk = func(start int, current []string) {
+		if len(current) == size {
+			result = append(result, append([]string{}, current...))
+			return
+		}
+		for i := start; i < len(levels); i++ {
+			next := i + 1
+			if len(levels) < size {
+				next = i
+			}
+			walk(next, append(current, levels[i]))
+		}
+	}
+	walk(0, nil)
+	return result
+}
+
+func playTournamentRound(seats []string, maxTime time.Duration, stats map[string]*brainStats) {
+	var clock time.Duration
+	// Game mechanics count whole seconds, so the clock starts at a whole one to play the same with the same seed
+	start := time.Now().Truncate(time.Second)
+	now = func() time.Time { return start.Add(clock) }
+	defer func() { now = time.Now }()
+
+	round := &Round{PowerUps: make(map[Point]int), Bombs: make(map[Point]int), State: RUNNING, PowerUpWeights: conf.PowerUpWeights}
+	var cars []*simulatedCar
+	for seat, level := range seats {
+		p := Player{Name: fmt.Sprintf("%s %d", level, seat+1), Health: 100, Bot: true, Car: Car{Speed: 1}}
+		p.initPlayer(seat, 0)
+		round.Players = append(round.Players, p)
+		brain, _ := newBotBrain(level)
+		cars = append(cars, &simulatedCar{brain: brain, level: level, health: 100, died: -1})
+	}
+
+	for ; clock < maxTime; clock += tournamentTick {
+		for i, car := range cars {
+			player := &round.Players[i]
+			if player.Health <= 0 {
+				continue
+			}
+			if clock >= car.nextDecision {
+				player.applyBotAction(car.brain.Decide(round.snapshot(player)))
+				car.nextDecision = clock + car.brain.Interval()
+			}
+			if clock >= car.nextMove {
+				player.move(round)
+				car.nextMove = clock + player.moveDelay()
+			}
+		}
+
+		if clock%tournamentFrame == 0 {
+			alive := 0
+			for i := range round.Players {
+				if round.Players[i].Health > 0 {
+					round.Players[i].updateSpeed()
+					// Every car runs checkBomb for all cars
+					round.placeBombs()
+					alive++
+				}
+			}
+			round.limitHealth()
+			round.spawnPowerUp()
+			round.repairCars()
+
+			for i, car := range cars {
+				health := round.Players[i].Health
+				if health < car.health {
+					car.damage += car.health - health
+				}
+				car.health = health
+				if health <= 0 && car.died < 0 {
+					car.died = clock
+				}
+			}
+			if alive <= 1 {
+				break
+			}
+		}
+	}
+
+	winner := -1
+	for i := range round.Players {
+		if round.Players[i].Health > 0 {
+			if winner != -1 {
+				// More than one car survived the time
+				winner = -2
+				break
+			}
+			winner = i
+		}
+	}
+	for i, car := range cars {
+		s := stats[car.level]
+		s.Rounds++
+		s.Damage += car.damage
+		if car.died >= 0 {
+			s.Survived += car.died
+		} else {
+			s.Survived += clock
+		}
+		if winner == i {
+			s.Wins++
+		} else if winner == -2 && car.died < 0 {
+			s.Draws++
+		}
+	}
+}
+
+func printTournament(w io.Writer, stats map[string]*brainStats, rounds int, took time.Duration, seed int64) {
+	var sorted []*brainStats
+	for _, s := range stats {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Wins*sorted[j].Rounds != sorted[j].Wins*sorted[i].Rounds {
+			return sorted[i].Wins*sorted[j].Rounds > sorted[j].Wins*sorted[i].Rounds
+		}
+		return sorted[i].Level < sorted[j].Level
+	})
+
+	fmt.Fprintf(w, "%d rounds in %s, seed %d\n\n", rounds, took.Truncate(time.Millisecond), seed)
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "Level\tCars\tWins\tDraws\tWin rate\tAvg damage taken\tAvg survival\t")
+	for _, s := range sorted {
+		if s.Rounds == 0 {
+			fmt.Fprintf(table, "%s\t0\t0\t0\t-\t-\t-\t\n", s.Level)
+			continue
+		}
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%.1f%%\t%.1f\t%s\t\n",
+			s.Level, s.Rounds, s.Wins, s.Draws,
+			100*float64(s.Wins)/float64(s.Rounds),
+			float64(s.Damage)/float64(s.Rounds),
+			(s.Survived / time.Duration(s.Rounds)).Truncate(time.Second))
+	}
+	table.Flush()
+}
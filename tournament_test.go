@@ -0,0 +1,42 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func playSeededTournament(seed int64, matchups [][]string) map[string]brainStats {
+	rand.Seed(seed)
+	stats := make(map[string]*brainStats)
+	for _, seats := range matchups {
+		for _, level := range seats {
+			if stats[level] == nil {
+				stats[level] = &brainStats{Level: level}
+			}
+		}
+		playTournamentRound(seats, 20*time.Second, stats)
+	}
+
+	result := make(map[string]brainStats)
+	for level, s := range stats {
+		result[level] = *s
+	}
+	return result
+}
+
+// The seed decides everything, so the tournament can be replayed
+func TestTournamentSeed(t *testing.T) {
+	weights, _ := parsePowerUpWeights(defaultPowerUpWeights)
+	conf.PowerUpWeights = weights
+	matchups := [][]string{{"easy", "hard"}, {"nightmare", "easy", "normal", "hard", "easy"}}
+
+	for seed := int64(1); seed <= 2; seed++ {
+		first, second := playSeededTournament(seed, matchups), playSeededTournament(seed, matchups)
+		for level, s := range first {
+			if second[level] != s {
+				t.Errorf("Seed %d: %+v and %+v in the same tournament", seed, s, second[level])
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+Race tracks are text files in the tracks directory of the artifacts, drawn
+over the arena: the first line is the first row inside the walls and the first
+character is the first column. # is a wall, 1-9 are checkpoints passed in
+order with 1 as the start and finish line, and <, >, ^, v are the left upper
+corners of the cars on the start grid, facing the direction of the arrow.
+Seats take the start positions in reading order
+*/
+
+const tracksDir = "tracks"
+const trackExtension = ".txt"
+
+type trackStart struct {
+	Position  Point
+	Direction int
+}
+
+type raceTrack struct {
+	Name        string
+	Walls       map[Point]bool
+	Checkpoints [][]Point
+	Starts      []trackStart
+}
+
+var raceTracks map[string]*raceTrack
+
+var trackArrows = map[rune]int{'<': LEFT, '>': RIGHT, '^': UP, 'v': DOWN}
+
+// Broken tracks are skipped and reported in the error like scripts
+func loadTracks(artifacts string) (map[string]*raceTrack, error) {
+	tracks := make(map[string]*raceTrack)
+	paths, _ := filepath.Glob(filepath.Join(artifacts, tracksDir, "*"+trackExtension))
+
+	var errs []error
+	for _, path := range paths {
+		track, err := loadTrack(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Track %s: %w", path, err))
+			continue
+		}
+		tracks[track.Name] = track
+	}
+	return tracks, errors.Join(errs...)
+}
+
+func loadTrack(path string) (*raceTrack, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	track := &raceTrack{Name: strings.TrimSuffix(filepath.Base(path), trackExtension), Walls: make(map[Point]bool)}
+	checkpoints := make(map[int][]Point)
+	for row, line := range strings.Split(strings.TrimRight(string(src), "\n"), "\n") {
+		for column, char := range []rune(strings.TrimRight(line, "\r")) {
+			p := Point{column + 1, row + 1}
+			if char == ' ' {
+				continue
+			} else if p.X > mapWidth-nameTableWidth-1 || p.Y > mapHeight-2 {
+				return nil, fmt.Errorf("%d:%d is outside of the arena", row+1, column+1)
+			}
+
+			if direction, ok := trackArrows[char]; ok {
+				track.Starts = append(track.Starts, trackStart{p, direction})
+			} else if char == '#' {
+				track.Walls[p] = true
+			} else if char >= '1' && char <= '9' {
+				checkpoints[int(char-'1')] = append(checkpoints[int(char-'1')], p)
+			} else {
+				// Like 0 or the second digit of checkpoint 10, which would be lost silently
+				return nil, fmt.Errorf("%d:%d: unknown character %q, checkpoints are 1-9", row+1, column+1, char)
+			}
+		}
+	}
+
+	for i := 0; i < len(checkpoints); i++ {
+		if len(checkpoints[i]) == 0 {
+			return nil, fmt.Errorf("Checkpoint %d is missing", i+1)
+		}
+		track.Checkpoints = append(track.Checkpoints, checkpoints[i])
+	}
+	if len(track.Checkpoints) < 2 {
+		return nil, errors.New("At least 2 checkpoints are needed")
+	}
+	if len(track.Starts) < maxPlayersPerRound {
+		return nil, fmt.Errorf("%d start positions are needed", maxPlayersPerRound)
+	}
+	return track, nil
+}
+
+func trackNames() []string {
+	var names []string
+	for name := range raceTracks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Borders of the car standing on the start position
+func (start trackStart) borders() Rectangle {
+	if start.Direction == LEFT || start.Direction == RIGHT {
+		return newRectangle(start.Position.X, start.Position.Y, horizontalCarWidth, horizontalCarHeight)
+	}
+	return newRectangle(start.Position.X, start.Position.Y, verticalCarWidth, verticalCarHeight)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadTrack(t *testing.T) {
+	starts := "> > > > >"
+	tests := []struct {
+		name        string
+		src         string
+		starts      int
+		checkpoints int
+		walls       int
+		err         string
+	}{
+		{"plain", starts + "\n#1#\n 2\n", 5, 2, 2, ""},
+		{"windows", starts + "\r\n 1\r\n 2\r\n 3\r\n", 5, 3, 0, ""},
+		{"directions", "< > ^ v <\n1 2", 5, 2, 0, ""},
+		{"wide checkpoint", starts + "\n111\n 2\n", 5, 2, 0, ""},
+		{"missing checkpoint", starts + "\n1\n3\n", 0, 0, 0, "Checkpoint 2 is missing"},
+		{"single checkpoint", starts + "\n1\n", 0, 0, 0, "At least 2 checkpoints"},
+		{"few starts", "> > > >\n1\n2\n", 0, 0, 0, "5 start positions"},
+		{"checkpoint 0", starts + "\n1\n2\n0\n", 0, 0, 0, "unknown character '0'"},
+		{"checkpoint 10", starts + "\n123456789\n10\n", 0, 0, 0, "unknown character '0'"},
+		{"unknown character", starts + "\n1\n2\n*\n", 0, 0, 0, "4:1: unknown character '*'"},
+		{"too wide", starts + "\n1\n2" + strings.Repeat(" ", mapWidth-nameTableWidth-1) + "#\n", 0, 0, 0, "outside of the arena"},
+		{"too high", starts + "\n1\n2" + strings.Repeat("\n", mapHeight-4) + "#\n", 0, 0, 0, "outside of the arena"},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), test.name+trackExtension)
+		if err := os.WriteFile(path, []byte(test.src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		track, err := loadTrack(path)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if track.Name != test.name || len(track.Starts) != test.starts ||
+			len(track.Checkpoints) != test.checkpoints || len(track.Walls) != test.walls {
+			t.Errorf("%s: got %q with %d starts, %d checkpoints and %d walls, want %d, %d and %d", test.name, track.Name,
+				len(track.Starts), len(track.Checkpoints), len(track.Walls), test.starts, test.checkpoints, test.walls)
+		}
+	}
+}
+
+func TestTrackPositions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "small"+trackExtension)
+	if err := os.WriteFile(path, []byte("> < ^ v >\n  11\n 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	track, err := loadTrack(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Columns and rows of the file start at the inner edge of the arena
+	wantStarts := []trackStart{{Point{1, 1}, RIGHT}, {Point{3, 1}, LEFT}, {Point{5, 1}, UP}, {Point{7, 1}, DOWN}, {Point{9, 1}, RIGHT}}
+	for i, want := range wantStarts {
+		if track.Starts[i] != want {
+			t.Errorf("Start %d = %v, want %v", i, track.Starts[i], want)
+		}
+	}
+	wantCheckpoints := [][]Point{{{3, 2}, {4, 2}}, {{2, 3}}}
+	for i, want := range wantCheckpoints {
+		if !slices.Equal(track.Checkpoints[i], want) {
+			t.Errorf("Checkpoint %d = %v, want %v", i+1, track.Checkpoints[i], want)
+		}
+	}
+}
+
+func TestShippedTracks(t *testing.T) {
+	tracks, err := loadTracks("artifacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracks["oval"] == nil {
+		t.Error("The oval track is not loaded")
+	}
+}